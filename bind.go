@@ -0,0 +1,333 @@
+package doze
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bindSources are the struct tags Bind reads, in the order they are tried
+var bindSources = []string{"path", "query", "header", "form"}
+
+const defaultMultipartMemory = 32 << 20
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// BindError lists every field Bind could not fill from the request.  It is
+// answered with 400 Bad Request
+type BindError struct {
+	Fields []FieldError
+}
+
+// Add appends a field that could not be bound to the BindError
+func (e *BindError) Add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{field, message})
+}
+
+// Err returns the BindError if any field was added, otherwise nil
+func (e *BindError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+func (e *BindError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+
+	return "binding failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *BindError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Bind fills the struct v points to from the request.  A JSON body is decoded
+// first using the json tags, then fields tagged with path, query, header or form
+// are set from the route params, the query string, the request headers and an
+// urlencoded or multipart form body.  A field can have several of these tags,
+// the first one in that order with a value in the request wins.  Fields with no
+// value in the request keep whatever they had.
+//
+// Strings, bools, ints, uints, floats, time.Duration and anything implementing
+// encoding.TextUnmarshaler, such as time.Time, are converted, as are pointers to
+// and slices of them.  A slice gets every value of a repeated query param,
+// header or form field.  Fields of embedded structs are bound as well.
+//
+// Every field that fails is collected in a *BindError.  The JSON body follows
+// the Handler's JSONOptions, and a JSON or form body over its MaxBytes returns a
+// *BodyTooLargeError instead
+func (c *Context) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("doze: Bind needs a non-nil pointer to a struct, got %T", v)
+	}
+
+	be := &BindError{}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request.Header.Get("Content-Type"))
+
+	if isJSONMediaType(mediaType) {
+		if err := c.bindJSONBody(v, be); err != nil {
+			return err
+		}
+	}
+
+	form, err := c.bindForm(mediaType)
+	if _, ok := err.(*BodyTooLargeError); ok {
+		return err
+	} else if err != nil {
+		be.Add("form", err.Error())
+	}
+
+	query := c.Request.URL.Query()
+
+	sources := map[string]func(string) []string{
+		"path": func(name string) []string {
+			if value, ok := c.pathValue(name); ok {
+				return []string{value}
+			}
+
+			return nil
+		},
+		"query":  func(name string) []string { return query[name] },
+		"header": c.Request.Header.Values,
+		"form":   func(name string) []string { return form[name] },
+	}
+
+	bindStruct(rv.Elem(), sources, be)
+
+	return be.Err()
+}
+
+// bindJSONBody adds the body's field errors to be, other errors such as an
+// oversized body are returned as they are
+func (c *Context) bindJSONBody(v interface{}, be *BindError) error {
+	err := c.decodeJSON(v, c.jsonOptions())
+
+	var (
+		syntaxErr  *JSONSyntaxError
+		typeErr    *JSONTypeError
+		unknownErr *JSONUnknownFieldError
+	)
+
+	switch {
+	case err == nil, errors.Is(err, io.EOF):
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		be.Add(typeErr.Field, fmt.Sprintf("expected %v, got %v", typeErr.Expected, typeErr.Got))
+	case errors.As(err, &unknownErr):
+		be.Add(unknownErr.Field, "is not allowed")
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		be.Add("body", err.Error())
+	default:
+		return err
+	}
+
+	return nil
+}
+
+// bindForm parses an urlencoded or multipart body, reading no more of it than the
+// Handler's JSONOptions allow so a multipart body cannot spool an unbounded
+// upload to disk
+func (c *Context) bindForm(mediaType string) (map[string][]string, error) {
+	if mediaType != "application/x-www-form-urlencoded" && mediaType != "multipart/form-data" {
+		return nil, nil
+	}
+
+	limit := c.jsonOptions().MaxBytes
+	if limit == 0 {
+		limit = DefaultMaxJSONBytes
+	}
+
+	if limit > 0 {
+		c.Request.Body = http.MaxBytesReader(c.ResponseWriter, c.Request.Body, limit)
+	}
+
+	var err error
+	if mediaType == "multipart/form-data" {
+		err = c.Request.ParseMultipartForm(defaultMultipartMemory)
+	} else {
+		err = c.Request.ParseForm()
+	}
+
+	var sizeErr *http.MaxBytesError
+	if errors.As(err, &sizeErr) {
+		return nil, &BodyTooLargeError{Limit: sizeErr.Limit}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if c.Request.MultipartForm != nil {
+		return c.Request.MultipartForm.Value, nil
+	}
+
+	return c.Request.PostForm, nil
+}
+
+// pathValue returns the raw value matched for the route param name
+func (c *Context) pathValue(name string) (string, bool) {
+	values := c.Route.ParamValues()
+
+	for i, n := range c.Route.ParamNames() {
+		if n == name && i < len(values) {
+			s, ok := values[i].(string)
+			return s, ok
+		}
+	}
+
+	return "", false
+}
+
+func bindStruct(rv reflect.Value, sources map[string]func(string) []string, be *BindError) {
+	t := rv.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := rv.Field(i)
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && !hasBindTag(sf) {
+			bindStruct(fv, sources, be)
+			continue
+		}
+
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		for _, source := range bindSources {
+			name := sf.Tag.Get(source)
+			if name == "" || name == "-" {
+				continue
+			}
+
+			values := sources[source](name)
+			if len(values) == 0 {
+				continue
+			}
+
+			if err := setValues(fv, values); err != nil {
+				be.Add(name, err.Error())
+			}
+
+			break
+		}
+	}
+}
+
+func hasBindTag(sf reflect.StructField) bool {
+	for _, source := range bindSources {
+		if _, ok := sf.Tag.Lookup(source); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func setValues(v reflect.Value, values []string) error {
+	if v.Kind() != reflect.Slice || v.Addr().Type().Implements(textUnmarshalerType) {
+		return setValue(v, values[0])
+	}
+
+	s := reflect.MakeSlice(v.Type(), len(values), len(values))
+
+	for i, value := range values {
+		if err := setValue(s.Index(i), value); err != nil {
+			return err
+		}
+	}
+
+	v.Set(s)
+
+	return nil
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		p := reflect.New(v.Type().Elem())
+
+		if err := setValue(p.Elem(), s); err != nil {
+			return err
+		}
+
+		v.Set(p)
+
+		return nil
+	}
+
+	invalid := fmt.Errorf("%q is not a valid %v", s, v.Type())
+
+	if v.Addr().Type().Implements(textUnmarshalerType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return invalid
+		}
+
+		return nil
+	}
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return invalid
+		}
+
+		v.SetInt(int64(d))
+
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return invalid
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return invalid
+		}
+
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return invalid
+		}
+
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return invalid
+		}
+
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot bind to %v", v.Type())
+	}
+
+	return nil
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,255 @@
+package doze
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Pagination struct {
+	Limit  int `query:"limit"`
+	Offset int `query:"offset"`
+}
+
+type BindTestRequest struct {
+	Pagination
+
+	ID      int64         `path:"id"`
+	Code    string        `path:"code"`
+	Tenant  string        `header:"X-Tenant"`
+	Active  *bool         `query:"active"`
+	Tags    []string      `query:"tag"`
+	IDs     []uint        `query:"ids"`
+	Since   time.Time     `query:"since"`
+	Timeout time.Duration `query:"timeout" header:"X-Timeout"`
+	Ratio   float32       `query:"ratio"`
+	Name    string        `json:"name" form:"name"`
+	Email   string        `json:"email"`
+	Ignored string        `query:"-"`
+	secret  string        `query:"secret"`
+}
+
+func bindRequest(req *http.Request, v interface{}) error {
+	router := Router("bindRequest")
+	router.Add(NewRoute().For("/users/{id:i}/{code}").With(MethodAny, func(c *Context) ResponseSender {
+		return NewErrorResponse(c.Bind(v))
+	}))
+
+	var err error
+
+	h := NewHandler(router)
+	h.ErrorHandler = func(c *Context, e error) ResponseSender {
+		err = e
+		return NewNoContentResponse()
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	return err
+}
+
+func TestBind(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost,
+		"/users/42/007?limit=10&offset=20&active=true&tag=a&tag=b&ids=1&ids=2&since=2024-03-01T12:00:00Z&timeout=1m30s&ratio=0.5&-=x&secret=x",
+		strings.NewReader(`{"name": "Mary", "email": "mary@example.com"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("X-Timeout", "5s")
+
+	var br BindTestRequest
+	err := bindRequest(req, &br)
+
+	assert.Nil(t, err, "should be nil")
+	assert.Equal(t, 10, br.Limit, "they should be equal")
+	assert.Equal(t, 20, br.Offset, "they should be equal")
+	assert.Equal(t, int64(42), br.ID, "they should be equal")
+	assert.Equal(t, "007", br.Code, "they should be equal")
+	assert.Equal(t, "acme", br.Tenant, "they should be equal")
+	assert.True(t, *br.Active, "should be true")
+	assert.Equal(t, []string{"a", "b"}, br.Tags, "they should be equal")
+	assert.Equal(t, []uint{1, 2}, br.IDs, "they should be equal")
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), br.Since, "they should be equal")
+	assert.Equal(t, 90*time.Second, br.Timeout, "query comes before header")
+	assert.Equal(t, float32(0.5), br.Ratio, "they should be equal")
+	assert.Equal(t, "Mary", br.Name, "they should be equal")
+	assert.Equal(t, "mary@example.com", br.Email, "they should be equal")
+	assert.Empty(t, br.Ignored, "should be empty")
+	assert.Empty(t, br.secret, "should be empty")
+}
+
+func TestBindKeepsDefaults(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/42/007", nil)
+
+	br := BindTestRequest{Pagination: Pagination{Limit: 25}}
+	err := bindRequest(req, &br)
+
+	assert.Nil(t, err, "should be nil")
+	assert.Equal(t, 25, br.Limit, "they should be equal")
+	assert.Nil(t, br.Active, "should be nil")
+	assert.Nil(t, br.Tags, "should be nil")
+}
+
+func TestBindForm(t *testing.T) {
+	form := url.Values{"name": {"Mary"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/users/42/007?limit=5", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var br BindTestRequest
+	err := bindRequest(req, &br)
+
+	assert.Nil(t, err, "should be nil")
+	assert.Equal(t, "Mary", br.Name, "they should be equal")
+	assert.Equal(t, 5, br.Limit, "they should be equal")
+}
+
+func TestBindError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/42/007?limit=ten&active=maybe&ids=1&ids=-2&since=yesterday&timeout=soon", nil)
+
+	var br BindTestRequest
+	err := bindRequest(req, &br)
+
+	var be *BindError
+
+	assert.True(t, errors.As(err, &be), "should be a BindError")
+	assert.Equal(t, http.StatusBadRequest, be.StatusCode(), "they should be equal")
+	assert.Equal(t, []FieldError{
+		{"limit", `"ten" is not a valid int`},
+		{"active", `"maybe" is not a valid bool`},
+		{"ids", `"-2" is not a valid uint`},
+		{"since", `"yesterday" is not a valid time.Time`},
+		{"timeout", `"soon" is not a valid time.Duration`},
+	}, be.Fields, "they should be equal")
+}
+
+func TestBindJSONError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/users/42/007", strings.NewReader(`{"name": 5}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	var br BindTestRequest
+	err := bindRequest(req, &br)
+
+	var be *BindError
+
+	assert.True(t, errors.As(err, &be), "should be a BindError")
+	assert.Equal(t, []FieldError{{"name", "expected string, got number"}}, be.Fields, "they should be equal")
+}
+
+func TestBindNeedsStructPointer(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/42/007", nil)
+
+	var br BindTestRequest
+	err := bindRequest(req, br)
+
+	var be *BindError
+
+	assert.NotNil(t, err, "should not be nil")
+	assert.False(t, errors.As(err, &be), "should not be a BindError")
+}
+
+func TestBindRemovesMultipartFiles(t *testing.T) {
+	type Upload struct {
+		Name string `form:"name"`
+	}
+
+	var (
+		u  Upload
+		fh *multipart.FileHeader
+	)
+
+	router := Router("TestBindRemovesMultipartFiles")
+	router.Add(NewRoute().For("/uploads").With(http.MethodPost, func(c *Context) ResponseSender {
+		c.Set("user", "mary")
+
+		if err := c.Bind(&u); err != nil {
+			return NewErrorResponse(err)
+		}
+
+		fh = c.Request.MultipartForm.File["doc"][0]
+
+		f, err := fh.Open()
+		if err != nil {
+			return NewErrorResponse(err)
+		}
+		f.Close()
+
+		return NewNoContentResponse()
+	}))
+
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("name", "report")
+	w, _ := mw.CreateFormFile("doc", "big.bin")
+	w.Write(bytes.Repeat([]byte("a"), defaultMultipartMemory+1))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/uploads", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	h := NewHandler(router)
+	h.JSON.MaxBytes = 2 * defaultMultipartMemory
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNoContent, resp.Code, "they should be equal")
+	assert.Equal(t, "report", u.Name, "they should be equal")
+
+	_, err := fh.Open()
+
+	assert.True(t, errors.Is(err, os.ErrNotExist), "temporary file should be removed")
+}
+
+func TestBindFormTooLarge(t *testing.T) {
+	type Upload struct {
+		Name string `form:"name"`
+	}
+
+	var err error
+
+	router := Router("TestBindFormTooLarge")
+	router.Add(NewRoute().For("/uploads").With(http.MethodPost, func(c *Context) ResponseSender {
+		err = c.Bind(&Upload{})
+
+		return NewErrorResponse(err)
+	}))
+
+	h := NewHandler(router)
+	h.JSON.MaxBytes = 1024
+
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("name", "report")
+	w, _ := mw.CreateFormFile("doc", "big.bin")
+	w.Write(bytes.Repeat([]byte("a"), 2048))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/uploads", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	assert.Equal(t, &BodyTooLargeError{Limit: 1024}, err, "they should be equal")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code, "they should be equal")
+
+	req = httptest.NewRequest(http.MethodPost, "/uploads", strings.NewReader("name="+strings.Repeat("a", 2048)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	assert.Equal(t, &BodyTooLargeError{Limit: 1024}, err, "they should be equal")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.Code, "they should be equal")
+}
@@ -0,0 +1,377 @@
+package doze
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressMinSize is the smallest body Compress compresses when its
+// MinSize is 0
+const DefaultCompressMinSize = 1024
+
+// CompressOptions configures the Compress middleware
+type CompressOptions struct {
+	// Level is a compress/flate level, flate.DefaultCompression when 0
+	Level int
+
+	// MinSize is the smallest body in bytes worth compressing.  Smaller bodies
+	// are sent as they are, DefaultCompressMinSize when 0
+	MinSize int
+}
+
+// already compressed media types that would only grow by compressing them again
+var compressedTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-bzip2",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/zstd",
+	"application/pdf",
+}
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// Compress returns a MiddlewareFunc that compresses responses with gzip or
+// deflate, whichever the request's Accept-Encoding prefers.  Responses are sent
+// as they are when they are smaller than MinSize, already have a
+// Content-Encoding, are a partial response or have an already compressed
+// Content-Type.  A compressed response has its ETag weakened and Accept-Ranges
+// removed, as byte ranges of the original body do not apply to it.  It works
+// with any ResponseSender as it compresses whatever is written to the
+// ResponseWriter
+func Compress(opts CompressOptions) MiddlewareFunc {
+	level := opts.Level
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+
+	minSize := opts.MinSize
+	if minSize == 0 {
+		minSize = DefaultCompressMinSize
+	}
+
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() interface{} {
+			w, err := gzip.NewWriterLevel(io.Discard, level)
+			if err != nil {
+				w = gzip.NewWriter(io.Discard)
+			}
+			return w
+		}},
+		"deflate": {New: func() interface{} {
+			w, err := zlib.NewWriterLevel(io.Discard, level)
+			if err != nil {
+				w = zlib.NewWriter(io.Discard)
+			}
+			return w
+		}},
+	}
+
+	return func(c *Context, next NextFunc) {
+		c.ResponseWriter.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := acceptedEncoding(c.Request.Header.Get("Accept-Encoding"))
+
+		rw := responseOf(c.ResponseWriter)
+		if encoding == "" || rw == nil {
+			next(c)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: rw.ResponseWriter,
+			encoding:       encoding,
+			pool:           pools[encoding],
+			minSize:        minSize,
+			status:         http.StatusOK,
+		}
+
+		rw.ResponseWriter = cw
+		defer func() {
+			rw.ResponseWriter = cw.ResponseWriter
+
+			// leave the response alone for whoever recovers the panic.  If only
+			// the buffer saw what was written so far it can still be replaced
+			if v := recover(); v != nil {
+				if !cw.decided {
+					rw.wroteHeader = false
+					rw.status = 0
+					rw.size = 0
+				}
+
+				cw.abandon()
+				panic(v)
+			}
+
+			cw.Close()
+		}()
+
+		next(c)
+	}
+}
+
+// acceptedEncoding returns gzip or deflate depending on the Accept-Encoding
+// header, preferring gzip on a tie, or an empty string if neither is accepted
+func acceptedEncoding(header string) string {
+	q := map[string]float64{}
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if coding == "" {
+			continue
+		}
+
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if f, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					weight = f
+				}
+			}
+		}
+
+		q[coding] = weight
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range []string{"gzip", "deflate"} {
+		weight, ok := q[coding]
+		if !ok {
+			weight, ok = q["*"]
+		}
+
+		if ok && weight > bestQ {
+			best, bestQ = coding, weight
+		}
+	}
+
+	return best
+}
+
+// compressWriter holds back the header and the start of the body until it knows
+// whether the response is worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	pool     *sync.Pool
+	minSize  int
+
+	status    int
+	statusSet bool
+	buf       []byte
+	decided   bool
+	closed    bool
+	cw        compressor
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided {
+		return
+	}
+
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.status = code
+	w.statusSet = true
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+
+		if len(w.buf) < w.minSize {
+			return len(b), nil
+		}
+
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+
+		return len(b), nil
+	}
+
+	if w.cw != nil {
+		return w.cw.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// decide sends the header, compressed or not, followed by anything buffered
+func (w *compressWriter) decide(bigEnough bool) error {
+	w.decided = true
+
+	h := w.Header()
+
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// sniff now, net/http would only see the compressed bytes
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if bigEnough && w.compressible() {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+
+		// the compressed body is a different representation, so it cannot share
+		// a strong validator with the identity one or be resumed by byte range
+		h.Del("Accept-Ranges")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+
+		w.cw = w.pool.Get().(compressor)
+		w.cw.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
+
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+
+	if w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified || w.status == http.StatusPartialContent {
+		return false
+	}
+
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+
+	contentType := strings.ToLower(h.Get("Content-Type"))
+	if contentType == "image/svg+xml" || strings.HasPrefix(contentType, "image/svg+xml;") {
+		return true
+	}
+
+	for _, t := range compressedTypes {
+		if strings.HasPrefix(contentType, t) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FlushError compresses and sends whatever has been written so far.  A response
+// that is flushed before reaching MinSize is still compressed, as it is a stream
+func (w *compressWriter) FlushError() error {
+	if !canFlush(w.ResponseWriter) {
+		return fmt.Errorf("doze: flush: %w", http.ErrNotSupported)
+	}
+
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return err
+		}
+	}
+
+	if w.cw != nil {
+		if err := w.cw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack gives up on compressing, the connection belongs to the caller
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.decided = true
+		w.closed = true
+	}
+
+	return conn, brw, err
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// abandon drops anything buffered and returns the compressor to its pool without
+// writing anything more
+func (w *compressWriter) abandon() {
+	w.closed = true
+	w.decided = true
+	w.buf = nil
+
+	if w.cw != nil {
+		w.cw.Reset(io.Discard)
+		w.pool.Put(w.cw)
+		w.cw = nil
+	}
+}
+
+// Close sends a response that never reached MinSize and finishes the compressed
+// stream.  Nothing is sent when nothing was written, so the status code is still
+// up to the middleware further out
+func (w *compressWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+
+	if !w.decided {
+		if len(w.buf) == 0 && !w.statusSet {
+			return nil
+		}
+
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+
+	if w.cw == nil {
+		return nil
+	}
+
+	err := w.cw.Close()
+
+	w.cw.Reset(io.Discard)
+	w.pool.Put(w.cw)
+	w.cw = nil
+
+	return err
+}
@@ -0,0 +1,257 @@
+package doze
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCompressHandler(name string) *Handler {
+	large := strings.Repeat("compress me ", 200)
+
+	router := Router(name)
+	router.Add(NewRoute().For("/large").With(http.MethodGet, func(c *Context) ResponseSender {
+		return NewOKJSONResponse(TestStruct{large})
+	}))
+	router.Add(NewRoute().For("/small").With(http.MethodGet, TestController{}.SimpleGet))
+	router.Add(NewRoute().For("/image").With(http.MethodGet, func(c *Context) ResponseSender {
+		return BasicResponse{StatusCode: http.StatusOK, Headers: map[string]string{"Content-Type": "image/png"}, Body: []byte(large)}
+	}))
+	router.Add(NewRoute().For("/sniffed").With(http.MethodGet, func(c *Context) ResponseSender {
+		return BasicResponse{StatusCode: http.StatusOK, Body: []byte("<html>" + large + "</html>")}
+	}))
+	router.Add(NewRoute().For("/gzipped").With(http.MethodGet, func(c *Context) ResponseSender {
+		return NewGzipResponse(BasicResponse{StatusCode: http.StatusOK, Body: []byte(large)})
+	}))
+
+	h := NewHandler(router)
+	h.Use(Compress(CompressOptions{}))
+
+	return h
+}
+
+func TestCompressNegotiation(t *testing.T) {
+	h := newCompressHandler("TestCompressNegotiation")
+
+	tests := map[string]string{
+		"":                          "",
+		"identity":                  "",
+		"gzip":                      "gzip",
+		"deflate":                   "deflate",
+		"gzip, deflate, br":         "gzip",
+		"gzip;q=0.5, deflate":       "deflate",
+		"gzip;q=0, *":               "deflate",
+		"*":                         "gzip",
+		"br, deflate;q=0, gzip;q=0": "",
+	}
+
+	for accept, expected := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/large", nil)
+		req.Header.Set("Accept-Encoding", accept)
+
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		assert.Equal(t, expected, resp.Header().Get("Content-Encoding"), "they should be equal for "+accept)
+		assert.Equal(t, "Accept-Encoding", resp.Header().Get("Vary"), "they should be equal for "+accept)
+
+		var body []byte
+		switch expected {
+		case "gzip":
+			r, _ := gzip.NewReader(resp.Body)
+			body, _ = ioutil.ReadAll(r)
+		case "deflate":
+			r, _ := zlib.NewReader(resp.Body)
+			body, _ = ioutil.ReadAll(r)
+		default:
+			body = resp.Body.Bytes()
+		}
+
+		assert.Contains(t, string(body), "compress me compress me", "body should decode for "+accept)
+	}
+}
+
+func TestCompressSkips(t *testing.T) {
+	h := newCompressHandler("TestCompressSkips")
+
+	for _, path := range []string{"/small", "/image"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code, "they should be equal for "+path)
+		assert.Empty(t, resp.Header().Get("Content-Encoding"), "should not be compressed for "+path)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/small", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	assert.Equal(t, `{"Message":"Simple Get"}`, resp.Body.String(), "they should be equal")
+}
+
+func TestCompressSniffsAndNoDoubleCompression(t *testing.T) {
+	h := newCompressHandler("TestCompressSniffsAndNoDoubleCompression")
+
+	req := httptest.NewRequest(http.MethodGet, "/sniffed", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	assert.Equal(t, "gzip", resp.Header().Get("Content-Encoding"), "they should be equal")
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header().Get("Content-Type"), "content type should be sniffed before compressing")
+
+	req = httptest.NewRequest(http.MethodGet, "/gzipped", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	r, err := gzip.NewReader(resp.Body)
+	assert.Nil(t, err, "error should be nil")
+
+	body, _ := ioutil.ReadAll(r)
+
+	assert.True(t, strings.HasPrefix(string(body), "compress me"), "body should only be compressed once")
+}
+
+func TestCompressStreaming(t *testing.T) {
+	ch := make(chan interface{}, 2)
+	ch <- TestStruct{"one"}
+	ch <- TestStruct{"two"}
+	close(ch)
+
+	router := Router("TestCompressStreaming")
+	router.Add(NewRoute().For("/stream").With(http.MethodGet, func(c *Context) ResponseSender {
+		nr := NewNDJSONResponse(ch)
+		nr.FlushSize = 1
+
+		return nr
+	}))
+
+	h := NewHandler(router)
+	h.Use(Compress(CompressOptions{}))
+
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	assert.Equal(t, "gzip", resp.Header().Get("Content-Encoding"), "flushed streams should be compressed")
+	assert.True(t, resp.Flushed, "response should be flushed")
+
+	r, _ := gzip.NewReader(resp.Body)
+	body, _ := ioutil.ReadAll(r)
+
+	assert.Equal(t, "{\"Message\":\"one\"}\n{\"Message\":\"two\"}\n", string(body), "they should be equal")
+}
+
+func TestCompressLeavesUnwrittenResponses(t *testing.T) {
+	router := Router("TestCompressLeavesUnwrittenResponses")
+	router.Add(NewRoute().For("/panic").With(http.MethodGet, func(c *Context) ResponseSender {
+		panic("boom")
+	}))
+	router.Add(NewRoute().For("/nothing").With(http.MethodGet, func(c *Context) ResponseSender {
+		return nil
+	}))
+	router.Add(NewRoute().For("/partial").With(http.MethodGet, func(c *Context) ResponseSender {
+		c.ResponseWriter.WriteHeader(http.StatusAccepted)
+		c.ResponseWriter.Write([]byte("partial"))
+		panic("boom")
+	}))
+
+	h := NewHandler(router)
+	h.ErrorLog = log.New(io.Discard, "", 0)
+	h.Use(Recovery(nil))
+	h.Use(func(c *Context, next NextFunc) {
+		next(c)
+
+		if !c.ResponseWriter.Written() {
+			c.ResponseWriter.WriteHeader(http.StatusNotFound)
+		}
+	})
+	h.Use(Compress(CompressOptions{}))
+
+	tests := []struct {
+		path, body string
+		code       int
+	}{
+		{"/panic", "Internal Server Error", http.StatusInternalServerError},
+		{"/nothing", "", http.StatusNotFound},
+		{"/partial", "Internal Server Error", http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		assert.Equal(t, test.code, resp.Code, "they should be equal for "+test.path)
+		assert.Equal(t, test.body, resp.Body.String(), "they should be equal for "+test.path)
+		assert.Empty(t, resp.Header().Get("Content-Encoding"), "should not be compressed for "+test.path)
+	}
+}
+
+func TestCompressRangedContent(t *testing.T) {
+	content := strings.Repeat("resume me ", 500)
+
+	router := Router("TestCompressRangedContent")
+	router.Add(NewRoute().For("/download").With(http.MethodGet, func(c *Context) ResponseSender {
+		cr := NewContentResponse("download.txt", time.Time{}, strings.NewReader(content))
+		cr.ETag = "v1"
+
+		return cr
+	}))
+
+	h := NewHandler(router)
+	h.Use(Compress(CompressOptions{}))
+
+	req := httptest.NewRequest(http.MethodGet, "/download", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	assert.Equal(t, "gzip", resp.Header().Get("Content-Encoding"), "they should be equal")
+	assert.Equal(t, `W/"v1"`, resp.Header().Get("ETag"), "a compressed body should only have a weak etag")
+	assert.Empty(t, resp.Header().Get("Accept-Ranges"), "a compressed body should not offer ranges")
+
+	// resuming with the weak etag gets the whole body instead of identity bytes
+	req.Header.Set("Range", "bytes=100-")
+	req.Header.Set("If-Range", resp.Header().Get("ETag"))
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	gz, _ := gzip.NewReader(resp.Body)
+	body, _ := ioutil.ReadAll(gz)
+
+	assert.Equal(t, http.StatusOK, resp.Code, "they should be equal")
+	assert.Equal(t, content, string(body), "they should be equal")
+
+	req = httptest.NewRequest(http.MethodGet, "/download", nil)
+	req.Header.Set("If-None-Match", `W/"v1"`)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusNotModified, resp.Code, "the weak etag should still revalidate")
+}
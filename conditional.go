@@ -0,0 +1,200 @@
+package doze
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ConditionalResponse wraps a BasicResponse with the validators clients use to
+// revalidate it.  GET and HEAD requests whose If-None-Match or If-Modified-Since
+// still match get 304 Not Modified without a body instead
+type ConditionalResponse struct {
+	BasicResponse
+	ETag         string
+	LastModified time.Time
+}
+
+// NewETagResponse returns a ConditionalResponse with a strong ETag computed from
+// the body
+func NewETagResponse(br BasicResponse) ConditionalResponse {
+	return ConditionalResponse{BasicResponse: br, ETag: bodyETag(br.Body, false)}
+}
+
+// NewWeakETagResponse returns a ConditionalResponse with a weak ETag computed from
+// the body, for bodies that are equivalent but not always byte for byte the same
+func NewWeakETagResponse(br BasicResponse) ConditionalResponse {
+	return ConditionalResponse{BasicResponse: br, ETag: bodyETag(br.Body, true)}
+}
+
+// NewConditionalResponse returns a ConditionalResponse with validators supplied
+// by the action, such as a version number and the time a record was updated.
+// Either can be left empty.  An unquoted etag is quoted
+func NewConditionalResponse(br BasicResponse, etag string, lastModified time.Time) ConditionalResponse {
+	return ConditionalResponse{BasicResponse: br, ETag: quoteETag(etag), LastModified: lastModified}
+}
+
+// Send writes the response, or 304 Not Modified when the client already has it
+func (cr ConditionalResponse) Send(w io.Writer) (int, error) {
+	headers := make(map[string]string, len(cr.Headers)+2)
+	for k, v := range cr.Headers {
+		headers[k] = v
+	}
+
+	if cr.ETag != "" {
+		headers["ETag"] = cr.ETag
+	}
+
+	if !cr.LastModified.IsZero() {
+		headers["Last-Modified"] = cr.LastModified.UTC().Format(http.TimeFormat)
+	}
+
+	br := cr.BasicResponse
+	br.Headers = headers
+
+	if rw, ok := w.(ResponseWriter); ok {
+		if r := rw.Request(); r != nil && cr.notModified(r) {
+			delete(headers, "Content-Type")
+			delete(headers, "Content-Length")
+
+			return BasicResponse{StatusCode: http.StatusNotModified, Headers: headers}.Send(w)
+		}
+	}
+
+	return br.Send(w)
+}
+
+func (cr ConditionalResponse) notModified(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if cr.StatusCode < 200 || cr.StatusCode >= 300 {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return cr.ETag != "" && etagListMatches(inm, cr.ETag, true)
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !cr.LastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+
+		return err == nil && !cr.LastModified.Truncate(time.Second).After(t)
+	}
+
+	return false
+}
+
+func bodyETag(body []byte, weak bool) string {
+	sum := sha1.Sum(body)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`
+
+	if weak {
+		return "W/" + etag
+	}
+
+	return etag
+}
+
+// quoteETag turns a bare version into a strong entity tag
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+
+	return `"` + etag + `"`
+}
+
+// etagListMatches reports whether an If-Match or If-None-Match header matches the
+// etag.  Weak comparison ignores the W/ prefix, strong comparison never matches
+// a weak entity tag
+func etagListMatches(header, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if !strings.HasPrefix(candidate, "W/") && !strings.HasPrefix(etag, "W/") && candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequireVersion checks the request's If-Match header against the current etag
+// of the resource, so an update made from a stale copy is refused instead of
+// overwriting someone else's change.  It returns ErrPreconditionFailed when they
+// differ and nil when they match or the request has no If-Match.  An unquoted
+// etag is quoted and weak etags never match
+func (c *Context) RequireVersion(etag string) error {
+	header := c.Request.Header.Get("If-Match")
+	if header == "" {
+		return nil
+	}
+
+	etag = quoteETag(etag)
+
+	if etag == "" || !etagListMatches(header, etag, false) {
+		return ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// RequireUnmodifiedSince checks the request's If-Unmodified-Since header against
+// the time the resource last changed.  It returns ErrPreconditionFailed when the
+// resource changed after that and nil otherwise.  The header is ignored when the
+// request has an If-Match, which RequireVersion checks instead
+func (c *Context) RequireUnmodifiedSince(lastModified time.Time) error {
+	if c.Request.Header.Get("If-Match") != "" {
+		return nil
+	}
+
+	header := c.Request.Header.Get("If-Unmodified-Since")
+	if header == "" {
+		return nil
+	}
+
+	t, err := http.ParseTime(header)
+	if err != nil {
+		return nil
+	}
+
+	if lastModified.Truncate(time.Second).After(t) {
+		return ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// RequirePrecondition returns a MiddlewareFunc that answers PUT, PATCH and DELETE
+// requests without an If-Match or If-Unmodified-Since header with 428
+// Precondition Required, through the Handler's ErrorHandler.  Add it to the
+// routes or groups whose actions call RequireVersion, so clients cannot skip
+// the check by leaving the header out
+func RequirePrecondition() MiddlewareFunc {
+	return func(c *Context, next NextFunc) {
+		switch c.Request.Method {
+		case http.MethodPut, http.MethodPatch, http.MethodDelete:
+			if c.Request.Header.Get("If-Match") == "" && c.Request.Header.Get("If-Unmodified-Since") == "" {
+				doAction(c, func(c *Context) ResponseSender {
+					return NewErrorResponse(ErrPreconditionRequired)
+				})
+				return
+			}
+		}
+
+		next(c)
+	}
+}
@@ -0,0 +1,164 @@
+package doze
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConditionalResponseETag(t *testing.T) {
+	router := Router("TestConditionalResponseETag")
+	router.Add(NewRoute().For("/strong").With(http.MethodGet, func(c *Context) ResponseSender {
+		return NewETagResponse(NewOKJSONResponse(TestStruct{"cached"}))
+	}))
+	router.Add(NewRoute().For("/weak").With(http.MethodGet, func(c *Context) ResponseSender {
+		return NewWeakETagResponse(NewOKJSONResponse(TestStruct{"cached"}))
+	}))
+
+	h := NewHandler(router)
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/strong", nil))
+
+	etag := resp.Header().Get("ETag")
+
+	assert.Equal(t, http.StatusOK, resp.Code, "they should be equal")
+	assert.Regexp(t, `^"[\w-]+"$`, etag, "should be a strong etag")
+
+	tests := []struct {
+		path, method, ifNoneMatch string
+		code                      int
+	}{
+		{"/strong", http.MethodGet, etag, http.StatusNotModified},
+		{"/strong", http.MethodHead, etag, http.StatusNotModified},
+		{"/strong", http.MethodGet, `"other", ` + etag, http.StatusNotModified},
+		{"/strong", http.MethodGet, "W/" + etag, http.StatusNotModified},
+		{"/strong", http.MethodGet, "*", http.StatusNotModified},
+		{"/strong", http.MethodGet, `"other"`, http.StatusOK},
+		{"/weak", http.MethodGet, etag, http.StatusNotModified},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		req.Header.Set("If-None-Match", test.ifNoneMatch)
+
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		msg := fmt.Sprintf("they should be equal for %v %v %v", test.method, test.path, test.ifNoneMatch)
+
+		assert.Equal(t, test.code, resp.Code, msg)
+
+		if test.code == http.StatusNotModified {
+			assert.Empty(t, resp.Body.String(), msg)
+			assert.Empty(t, resp.Header().Get("Content-Type"), msg)
+			assert.NotEmpty(t, resp.Header().Get("ETag"), msg)
+		}
+	}
+}
+
+func TestConditionalResponseSuppliedValidators(t *testing.T) {
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+
+	router := Router("TestConditionalResponseSuppliedValidators")
+	router.Add(NewRoute().For("/users/{id:i}").With(http.MethodGet, func(c *Context) ResponseSender {
+		return NewConditionalResponse(NewOKJSONResponse(TestStruct{"user"}), "v7", updated)
+	}).And(http.MethodPost, func(c *Context) ResponseSender {
+		return NewConditionalResponse(NewOKJSONResponse(TestStruct{"user"}), "v7", updated)
+	}))
+
+	h := NewHandler(router)
+
+	tests := []struct {
+		method string
+		header map[string]string
+		code   int
+	}{
+		{http.MethodGet, nil, http.StatusOK},
+		{http.MethodGet, map[string]string{"If-None-Match": `"v7"`}, http.StatusNotModified},
+		{http.MethodGet, map[string]string{"If-None-Match": `"v6"`}, http.StatusOK},
+		{http.MethodGet, map[string]string{"If-Modified-Since": updated.Format(http.TimeFormat)}, http.StatusNotModified},
+		{http.MethodGet, map[string]string{"If-Modified-Since": updated.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		// If-None-Match takes priority over If-Modified-Since
+		{http.MethodGet, map[string]string{"If-None-Match": `"v6"`, "If-Modified-Since": updated.Format(http.TimeFormat)}, http.StatusOK},
+		{http.MethodPost, map[string]string{"If-None-Match": `"v7"`}, http.StatusOK},
+	}
+
+	for i, test := range tests {
+		req := httptest.NewRequest(test.method, "/users/1", nil)
+		for k, v := range test.header {
+			req.Header.Set(k, v)
+		}
+
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		assert.Equal(t, test.code, resp.Code, fmt.Sprintf("they should be equal for test %v", i))
+		assert.Equal(t, `"v7"`, resp.Header().Get("ETag"), "they should be equal")
+		assert.Equal(t, "Fri, 01 Mar 2024 12:00:00 GMT", resp.Header().Get("Last-Modified"), "they should be equal")
+	}
+}
+
+func TestRequireVersion(t *testing.T) {
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	update := ErrorAction(func(c *Context) (ResponseSender, error) {
+		if err := c.RequireVersion("v7"); err != nil {
+			return nil, err
+		}
+
+		if err := c.RequireUnmodifiedSince(updated); err != nil {
+			return nil, err
+		}
+
+		return NewNoContentResponse(), nil
+	})
+
+	router := Router("TestRequireVersion")
+	router.Add(NewRoute().For("/users/{id:i}").
+		With(http.MethodPut, update).
+		And(http.MethodPatch, update).
+		And(http.MethodDelete, update).
+		Use(RequirePrecondition()))
+	router.Add(NewRoute().For("/notes/{id:i}").With(http.MethodPut, update))
+
+	h := NewHandler(router)
+
+	tests := []struct {
+		method, path string
+		header       map[string]string
+		code         int
+	}{
+		{http.MethodPut, "/users/1", nil, http.StatusPreconditionRequired},
+		{http.MethodPatch, "/users/1", nil, http.StatusPreconditionRequired},
+		{http.MethodDelete, "/users/1", nil, http.StatusPreconditionRequired},
+		{http.MethodPut, "/users/1", map[string]string{"If-Match": `"v7"`}, http.StatusNoContent},
+		{http.MethodPut, "/users/1", map[string]string{"If-Match": `"v6", "v7"`}, http.StatusNoContent},
+		{http.MethodPut, "/users/1", map[string]string{"If-Match": "*"}, http.StatusNoContent},
+		{http.MethodPut, "/users/1", map[string]string{"If-Match": `"v6"`}, http.StatusPreconditionFailed},
+		{http.MethodPut, "/users/1", map[string]string{"If-Match": `W/"v7"`}, http.StatusPreconditionFailed},
+		{http.MethodDelete, "/users/1", map[string]string{"If-Unmodified-Since": updated.Format(http.TimeFormat)}, http.StatusNoContent},
+		{http.MethodDelete, "/users/1", map[string]string{"If-Unmodified-Since": updated.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusPreconditionFailed},
+		// If-Unmodified-Since is ignored when If-Match is present
+		{http.MethodPut, "/users/1", map[string]string{"If-Match": `"v7"`, "If-Unmodified-Since": updated.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusNoContent},
+		// preconditions are optional without RequirePrecondition
+		{http.MethodPut, "/notes/1", nil, http.StatusNoContent},
+		{http.MethodPut, "/notes/1", map[string]string{"If-Match": `"v6"`}, http.StatusPreconditionFailed},
+	}
+
+	for i, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		for k, v := range test.header {
+			req.Header.Set(k, v)
+		}
+
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		assert.Equal(t, test.code, resp.Code, fmt.Sprintf("they should be equal for test %v", i))
+	}
+}
@@ -0,0 +1,70 @@
+package doze
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// ContentResponse sends a file or blob that clients can download in parts.  It
+// answers Range requests with 206 Partial Content, using a multipart/byteranges
+// body for several ranges, honours If-Range and answers unsatisfiable ranges
+// with 416 Range Not Satisfiable.  ModTime and ETag, when set, are sent as
+// validators and checked against If-Modified-Since, If-None-Match and the other
+// conditional headers.  The Content-Type comes from Headers, the extension of
+// Name or, failing both, the first bytes of Content.
+//
+// Content is closed after sending if it is an io.Closer, so an *os.File can be
+// handed over as it is
+type ContentResponse struct {
+	Name    string
+	ModTime time.Time
+	ETag    string
+	Content io.ReadSeeker
+	Headers map[string]string
+}
+
+// NewContentResponse returns a ContentResponse for content named name
+func NewContentResponse(name string, modTime time.Time, content io.ReadSeeker) ContentResponse {
+	return ContentResponse{Name: name, ModTime: modTime, Content: content}
+}
+
+// NewAttachmentResponse returns a ContentResponse that browsers save as a file
+// called name instead of displaying it
+func NewAttachmentResponse(name string, modTime time.Time, content io.ReadSeeker) ContentResponse {
+	cr := NewContentResponse(name, modTime, content)
+	cr.Headers = map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": name}),
+	}
+
+	return cr
+}
+
+// Send writes the content, or the requested ranges of it.  Without a
+// ResponseWriter there is no request to read ranges from, so all of it is sent
+func (cr ContentResponse) Send(w io.Writer) (int, error) {
+	if c, ok := cr.Content.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	rw, ok := w.(ResponseWriter)
+	if !ok || rw.Request() == nil {
+		n, err := io.Copy(w, cr.Content)
+		return int(n), err
+	}
+
+	for k, v := range cr.Headers {
+		rw.Header().Set(k, v)
+	}
+
+	if cr.ETag != "" {
+		rw.Header().Set("ETag", quoteETag(cr.ETag))
+	}
+
+	size := rw.Size()
+
+	http.ServeContent(rw, rw.Request(), cr.Name, cr.ModTime, cr.Content)
+
+	return rw.Size() - size, nil
+}
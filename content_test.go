@@ -0,0 +1,135 @@
+package doze
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentResponseRanges(t *testing.T) {
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	content := "0123456789abcdefghij"
+
+	router := Router("TestContentResponseRanges")
+	router.Add(NewRoute().For("/artifacts/{name}").With(http.MethodGet, func(c *Context) ResponseSender {
+		cr := NewAttachmentResponse("build.txt", modTime, strings.NewReader(content))
+		cr.ETag = "build-42"
+		return cr
+	}))
+
+	h := NewHandler(router)
+
+	tests := []struct {
+		method string
+		header map[string]string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, nil, http.StatusOK, content},
+		{http.MethodHead, nil, http.StatusOK, ""},
+		{http.MethodGet, map[string]string{"Range": "bytes=0-4"}, http.StatusPartialContent, "01234"},
+		{http.MethodGet, map[string]string{"Range": "bytes=15-"}, http.StatusPartialContent, "fghij"},
+		{http.MethodGet, map[string]string{"Range": "bytes=-3"}, http.StatusPartialContent, "hij"},
+		{http.MethodGet, map[string]string{"Range": "bytes=50-60"}, http.StatusRequestedRangeNotSatisfiable, ""},
+		{http.MethodGet, map[string]string{"Range": "bytes=0-4", "If-Range": `"build-42"`}, http.StatusPartialContent, "01234"},
+		{http.MethodGet, map[string]string{"Range": "bytes=0-4", "If-Range": `"build-41"`}, http.StatusOK, content},
+		{http.MethodGet, map[string]string{"Range": "bytes=0-4", "If-Range": modTime.Format(http.TimeFormat)}, http.StatusPartialContent, "01234"},
+		{http.MethodGet, map[string]string{"If-None-Match": `"build-42"`}, http.StatusNotModified, ""},
+	}
+
+	for i, test := range tests {
+		req := httptest.NewRequest(test.method, "/artifacts/build", nil)
+		for k, v := range test.header {
+			req.Header.Set(k, v)
+		}
+
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		msg := fmt.Sprintf("they should be equal for test %v", i)
+
+		assert.Equal(t, test.code, resp.Code, msg)
+
+		if test.code != http.StatusRequestedRangeNotSatisfiable {
+			assert.Equal(t, test.body, resp.Body.String(), msg)
+		}
+	}
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/artifacts/build", nil))
+
+	assert.Equal(t, "bytes", resp.Header().Get("Accept-Ranges"), "they should be equal")
+	assert.Equal(t, "text/plain; charset=utf-8", resp.Header().Get("Content-Type"), "they should be equal")
+	assert.Equal(t, `attachment; filename=build.txt`, resp.Header().Get("Content-Disposition"), "they should be equal")
+	assert.Equal(t, `"build-42"`, resp.Header().Get("ETag"), "they should be equal")
+	assert.Equal(t, modTime.Format(http.TimeFormat), resp.Header().Get("Last-Modified"), "they should be equal")
+}
+
+func TestContentResponseMultipartRanges(t *testing.T) {
+	router := Router("TestContentResponseMultipartRanges")
+	router.Add(NewRoute().For("/blob").With(http.MethodGet, func(c *Context) ResponseSender {
+		return NewContentResponse("blob.bin", time.Time{}, strings.NewReader("0123456789abcdefghij"))
+	}))
+
+	h := NewHandler(router)
+
+	req := httptest.NewRequest(http.MethodGet, "/blob", nil)
+	req.Header.Set("Range", "bytes=0-1,10-12")
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusPartialContent, resp.Code, "they should be equal")
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header().Get("Content-Type"))
+
+	assert.Nil(t, err, "should be nil")
+	assert.Equal(t, "multipart/byteranges", mediaType, "they should be equal")
+
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+
+	var parts []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+
+		assert.Nil(t, err, "should be nil")
+
+		b, _ := io.ReadAll(p)
+		parts = append(parts, p.Header.Get("Content-Range")+" "+string(b))
+	}
+
+	assert.Equal(t, []string{"bytes 0-1/20 01", "bytes 10-12/20 abc"}, parts, "they should be equal")
+}
+
+type closeRecorder struct {
+	*strings.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestContentResponseClosesContent(t *testing.T) {
+	content := &closeRecorder{Reader: strings.NewReader("data")}
+
+	var b strings.Builder
+	n, err := NewContentResponse("data.txt", time.Time{}, content).Send(&b)
+
+	assert.Nil(t, err, "should be nil")
+	assert.Equal(t, 4, n, "they should be equal")
+	assert.Equal(t, "data", b.String(), "they should be equal")
+	assert.True(t, content.closed, "should be closed")
+}
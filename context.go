@@ -2,16 +2,20 @@ package doze
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
 	"net/url"
 )
 
-// Context will contain all information about the current request scoped context
+// Context will contain all information about the current request scoped context.
+// Route is the match result for this request only, so its params are never
+// shared with other requests to the same route
 type Context struct {
 	Request        *http.Request
-	ResponseWriter *ResponseWriter
+	ResponseWriter ResponseWriter
 	Route          PatternedRoute
+
+	handler  *Handler
+	cleanups []func()
 }
 
 // Set puts a value on the current context.Context by key
@@ -26,6 +30,21 @@ func (c *Context) Value(key interface{}) interface{} {
 	return c.Request.Context().Value(key)
 }
 
+// Cleanup registers a function to run once the request has been handled and the
+// response sent, such as removing a temporary file.  Functions run in the
+// reverse order they were added, even when the chain panics
+func (c *Context) Cleanup(fn func()) {
+	c.cleanups = append(c.cleanups, fn)
+}
+
+func (c *Context) runCleanups() {
+	for i := len(c.cleanups) - 1; i >= 0; i-- {
+		c.cleanups[i]()
+	}
+
+	c.cleanups = nil
+}
+
 // FormData returns data related to the request from GET, POST, or PUT
 func (c *Context) FormData() url.Values {
 	c.Request.ParseForm()
@@ -39,7 +58,11 @@ func (c *Context) FormData() url.Values {
 	}
 }
 
-// BindJSONEntity binds the JSON body from the request to an interface{}
+// BindJSONEntity binds the JSON body from the request to the value i points to,
+// following the Handler's JSONOptions.  A Content-Type other than JSON is
+// refused with ErrUnsupportedMediaType, while a request without one is decoded
+// anyway.  A body that cannot be decoded returns a *JSONSyntaxError,
+// *JSONTypeError, *JSONUnknownFieldError or *BodyTooLargeError
 func (c *Context) BindJSONEntity(i interface{}) error {
-	return json.NewDecoder(c.Request.Body).Decode(&i)
+	return c.BindJSONEntityWith(i, c.jsonOptions())
 }
@@ -1,9 +1,17 @@
 package doze
 
 import (
+	"log"
 	"net/http"
+	"sort"
+	"strings"
 )
 
+// MethodAny can be used in place of a method when adding an action to a route.
+// The action then handles every method the route has no other action for,
+// instead of the automatic HEAD, OPTIONS and 405 Method Not Allowed responses
+const MethodAny = "*"
+
 // Routeable is an interface which allows you to create your own router
 // * Get(string) *Route returns the route by route name
 // * Match(string) *Route takes a URI and returns a *Route it matches.  If it does not
@@ -16,10 +24,36 @@ type Routeable interface {
 // ActionFunc is a type that is a function to be used as a controller action
 type ActionFunc func(*Context) ResponseSender
 
-// Handler implements http.Handler and contains the router and controllers for the REST api
+// ErrorHandlerFunc turns an error raised while handling a request into a response
+type ErrorHandlerFunc func(*Context, error) ResponseSender
+
+// Handler implements http.Handler and contains the router and controllers for the REST api.
+//
+// NotFound, MethodNotAllowed and ErrorHandler replace the default plain text
+// responses.  They run at the end of the same middleware chain as any other
+// action, so their responses go through the global middleware as well
 type Handler struct {
 	router     Routeable
 	middleware []MiddlewareFunc
+
+	// NotFound is called when no route matches the request path
+	NotFound ActionFunc
+
+	// MethodNotAllowed is called when the matched route has no action for the
+	// request method.  The Allow header is already set when it runs
+	MethodNotAllowed ActionFunc
+
+	// ErrorHandler is called with the error of any response created by
+	// NewErrorResponse or returned from an ErrorAction.  DefaultErrorHandler is
+	// used when it is nil
+	ErrorHandler ErrorHandlerFunc
+
+	// JSON configures how BindJSONEntity and Bind decode request bodies
+	JSON JSONOptions
+
+	// ErrorLog logs errors that cannot be sent as a response, such as a failed
+	// ResponseSender.Send.  The standard logger is used when it is nil
+	ErrorLog *log.Logger
 }
 
 // NewHandler returns a new Handler with router initialized
@@ -35,27 +69,149 @@ func (h *Handler) Use(mf MiddlewareFunc) {
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	route, matched := h.router.Match(r.URL.Path)
 	if !matched {
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-		return
+		route = PatternedRoute{Route: NewRoute()}
 	}
 
-	action, actionExists := route.Actions()[r.Method]
-	if !actionExists {
-		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
-		return
+	method := r.Method
+	actions := route.Actions()
+
+	action, actionExists := actions[method]
+	if !matched {
+		action = h.notFound()
+	} else if !actionExists {
+		if anyAction, ok := actions[MethodAny]; ok {
+			action = anyAction
+			method = MethodAny
+		} else if getAction, ok := actions[http.MethodGet]; ok && method == http.MethodHead {
+			// run the GET action but never send its body
+			action = getAction
+			method = http.MethodGet
+			w = headResponseWriter{w}
+		} else if method == http.MethodOptions {
+			action = optionsAction(allowedMethods(actions))
+		} else {
+			w.Header().Set("Allow", allowedMethods(actions))
+			action = h.methodNotAllowed()
+		}
 	}
 
+	rw := &responseWriter{ResponseWriter: w}
+
 	context := &Context{
 		Request:        r,
-		ResponseWriter: &ResponseWriter{w, 0, 0},
+		ResponseWriter: rw.wrap(),
 		Route:          route,
+		handler:        h,
 	}
+	rw.ctx = context
+
+	defer context.runCleanups()
+	defer removeMultipartForm(context)
 
 	mwc := &middlewareChain{action: action}
 	for _, mw := range h.middleware {
 		mwc.add(mw)
 	}
+	if rm, ok := route.Route.(routeMiddleware); ok {
+		for _, mw := range rm.Middleware() {
+			mwc.add(mw)
+		}
+		for _, mw := range rm.MethodMiddleware()[method] {
+			mwc.add(mw)
+		}
+	}
 
 	mwc.run(context)
 	return
 }
+
+// removeMultipartForm removes the temporary files of a multipart form parsed by
+// Bind or the action.  net/http only does so for the request it passed in, not
+// for a copy made by Context.Set
+func removeMultipartForm(c *Context) {
+	if c.Request.MultipartForm != nil {
+		c.Request.MultipartForm.RemoveAll()
+	}
+}
+
+func (h *Handler) notFound() ActionFunc {
+	if h.NotFound != nil {
+		return h.NotFound
+	}
+
+	return func(c *Context) ResponseSender {
+		return NewNotFoundResponse()
+	}
+}
+
+func (h *Handler) methodNotAllowed() ActionFunc {
+	if h.MethodNotAllowed != nil {
+		return h.MethodNotAllowed
+	}
+
+	return func(c *Context) ResponseSender {
+		return NewMethodNotAllowedResponse()
+	}
+}
+
+func (h *Handler) handleError(c *Context, err error) ResponseSender {
+	if h != nil && h.ErrorHandler != nil {
+		return h.ErrorHandler(c, err)
+	}
+
+	return DefaultErrorHandler(c, err)
+}
+
+func (h *Handler) logf(format string, args ...interface{}) {
+	if h != nil && h.ErrorLog != nil {
+		h.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// allowedMethods returns the value of the Allow header for a route's actions,
+// including the methods that are handled automatically
+func allowedMethods(actions map[string]ActionFunc) string {
+	methods := []string{http.MethodOptions}
+
+	for method := range actions {
+		if method != MethodAny && method != http.MethodOptions {
+			methods = append(methods, method)
+		}
+	}
+
+	if _, ok := actions[http.MethodGet]; ok {
+		if _, ok := actions[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+
+	sort.Strings(methods)
+
+	return strings.Join(methods, ", ")
+}
+
+func optionsAction(allow string) ActionFunc {
+	return func(c *Context) ResponseSender {
+		return BasicResponse{
+			StatusCode: http.StatusNoContent,
+			Headers:    map[string]string{"Allow": allow},
+		}
+	}
+}
+
+// headResponseWriter discards the body so a GET action can answer a HEAD request.
+// Unwrap lets http.ResponseController flush, hijack and set deadlines on the
+// wrapped writer, so streaming actions behave the same for HEAD as for GET
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
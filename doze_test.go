@@ -1,11 +1,15 @@
 package doze
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -117,3 +121,276 @@ func TestRestSimplePut(t *testing.T) {
 
 	assert.Equal(t, `{"Message":"Simple Put Updated"}`, string(body), "they should be equal")
 }
+
+// TestRestConcurrentParams is meant to be run with -race as well, matching must
+// never let one request see the params of another
+func TestRestConcurrentParams(t *testing.T) {
+	router := Router("TestRestConcurrentParams")
+	router.Add(NewRoute().For("/users/{id:i}/{name}").With(http.MethodGet, func(c *Context) ResponseSender {
+		params := c.Route.Params()
+
+		return NewOKJSONResponse(TestStruct{fmt.Sprintf("%v-%v", params["id"], params["name"])})
+	}))
+
+	h := NewHandler(router)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+
+		go func(g int) {
+			defer wg.Done()
+
+			for n := 0; n < 200; n++ {
+				id := g*1000 + n
+				expected := fmt.Sprintf(`{"Message":"%v-user%v"}`, id, id)
+
+				resp := httptest.NewRecorder()
+				h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/users/%v/user%v", id, id), nil))
+
+				if resp.Body.String() != expected {
+					t.Errorf("expected %v, got %v", expected, resp.Body.String())
+					return
+				}
+			}
+		}(g)
+	}
+
+	wg.Wait()
+}
+
+func TestRestGroupMiddleware(t *testing.T) {
+	trace := func(name string) MiddlewareFunc {
+		return func(c *Context, next NextFunc) {
+			c.ResponseWriter.Header().Add("X-Trace", name)
+			next(c)
+		}
+	}
+
+	router := Router("TestRestGroupMiddleware")
+	admin := router.Group("/admin", trace("admin"))
+	users := admin.Group("/users", trace("users"))
+
+	router.Add(NewRoute().For("/public").With(http.MethodGet, TestController{}.SimpleGet))
+	admin.Add(NewRoute().For("/status").With(http.MethodGet, TestController{}.SimpleGet))
+	users.Add(NewRoute().For("/{id:i}").With(http.MethodGet, TestController{}.SimpleGet))
+
+	h := NewHandler(router)
+	h.Use(trace("global"))
+
+	tests := map[string][]string{
+		"/public":        {"global"},
+		"/admin/status":  {"global", "admin"},
+		"/admin/users/1": {"global", "admin", "users"},
+	}
+
+	for path, expected := range tests {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+
+		assert.Equal(t, http.StatusOK, resp.Code, "they should be equal")
+		assert.Equal(t, expected, resp.Header()["X-Trace"], "middleware should run in order for "+path)
+	}
+}
+
+func TestRestRouteAndMethodMiddleware(t *testing.T) {
+	trace := func(name string) MiddlewareFunc {
+		return func(c *Context, next NextFunc) {
+			c.ResponseWriter.Header().Add("X-Trace", name)
+			next(c)
+		}
+	}
+
+	forbid := func(c *Context, next NextFunc) {
+		c.ResponseWriter.WriteHeader(http.StatusForbidden)
+	}
+
+	router := Router("TestRestRouteAndMethodMiddleware")
+	group := router.Group("/group", trace("group"))
+	group.Add(
+		NewRoute().
+			For("/items").
+			Use(trace("route")).
+			With(http.MethodGet, TestController{}.SimpleGet, trace("get")).
+			And(http.MethodPost, TestController{}.SimplePost, forbid),
+	)
+
+	h := NewHandler(router)
+	h.Use(trace("global"))
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/group/items", nil))
+
+	assert.Equal(t, http.StatusOK, resp.Code, "they should be equal")
+	assert.Equal(t, []string{"global", "group", "route", "get"}, resp.Header()["X-Trace"], "they should be equal")
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/group/items", strings.NewReader(`{"Message":"Post"}`)))
+
+	assert.Equal(t, http.StatusForbidden, resp.Code, "they should be equal")
+	assert.Equal(t, []string{"global", "group", "route"}, resp.Header()["X-Trace"], "they should be equal")
+}
+
+func TestRestAutomaticHead(t *testing.T) {
+	setup()
+	defer teardown()
+
+	h := NewHandler(r)
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodHead, RestRoot+"/simpleget", nil))
+
+	assert.Equal(t, http.StatusOK, resp.Code, "they should be equal")
+	assert.Equal(t, "application/json", resp.Header().Get("Content-Type"), "they should be equal")
+	assert.Empty(t, resp.Body.String(), "body should be empty")
+}
+
+func TestRestAutomaticHeadResponseController(t *testing.T) {
+	errs := make(chan [2]error, 1)
+
+	router := Router("TestRestAutomaticHeadResponseController")
+	router.Add(NewRoute().For("/stream").With(http.MethodGet, func(c *Context) ResponseSender {
+		rc := http.NewResponseController(c.ResponseWriter)
+
+		deadlineErr := rc.SetWriteDeadline(time.Now().Add(time.Minute))
+		errs <- [2]error{rc.Flush(), deadlineErr}
+
+		return nil
+	}))
+
+	server := httptest.NewServer(NewHandler(router))
+	defer server.Close()
+
+	resp, err := http.Head(server.URL + "/stream")
+
+	assert.Nil(t, err, "should be nil")
+	resp.Body.Close()
+
+	res := <-errs
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "they should be equal")
+	assert.Nil(t, res[0], "flush should be supported for HEAD")
+	assert.Nil(t, res[1], "deadlines should be supported for HEAD")
+}
+
+func TestRestAutomaticOptions(t *testing.T) {
+	router := Router("TestRestAutomaticOptions")
+	router.Add(NewRoute().For("/items").With(http.MethodGet, TestController{}.SimpleGet).And(http.MethodPost, TestController{}.SimplePost))
+
+	h := NewHandler(router)
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodOptions, "/items", nil))
+
+	assert.Equal(t, http.StatusNoContent, resp.Code, "they should be equal")
+	assert.Equal(t, "GET, HEAD, OPTIONS, POST", resp.Header().Get("Allow"), "they should be equal")
+}
+
+func TestRestMethodNotAllowedAllowHeader(t *testing.T) {
+	router := Router("TestRestMethodNotAllowedAllowHeader")
+	router.Add(NewRoute().For("/items").With(http.MethodPut, TestController{}.SimplePut).And(http.MethodDelete, TestController{}.SimpleGet))
+
+	h := NewHandler(router)
+
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost} {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest(method, "/items", nil))
+
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.Code, "they should be equal for "+method)
+		assert.Equal(t, "DELETE, OPTIONS, PUT", resp.Header().Get("Allow"), "they should be equal for "+method)
+	}
+}
+
+func TestRestOverrideAutomaticMethods(t *testing.T) {
+	status := func(code int) ActionFunc {
+		return func(c *Context) ResponseSender {
+			return BasicResponse{StatusCode: code}
+		}
+	}
+
+	router := Router("TestRestOverrideAutomaticMethods")
+	router.Add(
+		NewRoute().
+			For("/items").
+			With(http.MethodGet, TestController{}.SimpleGet).
+			And(http.MethodHead, status(http.StatusAccepted)).
+			And(http.MethodOptions, status(http.StatusOK)),
+	)
+	router.Add(NewRoute().For("/any").With(http.MethodGet, TestController{}.SimpleGet).And(MethodAny, status(http.StatusTeapot)))
+
+	h := NewHandler(router)
+
+	tests := []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodHead, "/items", http.StatusAccepted},
+		{http.MethodOptions, "/items", http.StatusOK},
+		{http.MethodGet, "/any", http.StatusOK},
+		{http.MethodPatch, "/any", http.StatusTeapot},
+		{http.MethodOptions, "/any", http.StatusTeapot},
+	}
+
+	for _, test := range tests {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest(test.method, test.path, nil))
+
+		assert.Equal(t, test.code, resp.Code, "they should be equal for "+test.method+" "+test.path)
+	}
+}
+
+func TestRestCustomNotFoundAndMethodNotAllowed(t *testing.T) {
+	router := Router("TestRestCustomNotFoundAndMethodNotAllowed")
+	router.Add(NewRoute().For("/items").With(http.MethodGet, TestController{}.SimpleGet))
+
+	h := NewHandler(router)
+	h.NotFound = func(c *Context) ResponseSender {
+		return BasicResponse{StatusCode: http.StatusNotFound, Body: []byte(`{"error":"not found"}`)}
+	}
+	h.MethodNotAllowed = func(c *Context) ResponseSender {
+		return BasicResponse{StatusCode: http.StatusMethodNotAllowed, Body: []byte(`{"error":"method not allowed"}`)}
+	}
+	h.Use(func(c *Context, next NextFunc) {
+		c.ResponseWriter.Header().Set("X-Middleware", "ran")
+		next(c)
+	})
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	assert.Equal(t, http.StatusNotFound, resp.Code, "they should be equal")
+	assert.Equal(t, `{"error":"not found"}`, resp.Body.String(), "they should be equal")
+	assert.Equal(t, "ran", resp.Header().Get("X-Middleware"), "they should be equal")
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/items", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code, "they should be equal")
+	assert.Equal(t, `{"error":"method not allowed"}`, resp.Body.String(), "they should be equal")
+	assert.Equal(t, "GET, HEAD, OPTIONS", resp.Header().Get("Allow"), "they should be equal")
+	assert.Equal(t, "ran", resp.Header().Get("X-Middleware"), "they should be equal")
+}
+
+func TestRestErrorHandler(t *testing.T) {
+	router := Router("TestRestErrorHandler")
+	router.Add(NewRoute().For("/fail").With(http.MethodGet, func(c *Context) ResponseSender {
+		return NewErrorResponse(errors.New("something broke"))
+	}))
+
+	h := NewHandler(router)
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code, "they should be equal")
+
+	h.ErrorHandler = func(c *Context, err error) ResponseSender {
+		return BasicResponse{StatusCode: http.StatusServiceUnavailable, Body: []byte(err.Error())}
+	}
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code, "they should be equal")
+	assert.Equal(t, "something broke", resp.Body.String(), "they should be equal")
+}
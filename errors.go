@@ -0,0 +1,142 @@
+package doze
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrorActionFunc is a controller action that can fail.  Use ErrorAction to add
+// it to a route
+type ErrorActionFunc func(*Context) (ResponseSender, error)
+
+// ErrorAction adapts an ErrorActionFunc to an ActionFunc.  A returned error is
+// handed to the Handler's ErrorHandler and the ResponseSender is ignored
+func ErrorAction(fn ErrorActionFunc) ActionFunc {
+	return func(c *Context) ResponseSender {
+		rs, err := fn(c)
+		if err != nil {
+			return NewErrorResponse(err)
+		}
+
+		return rs
+	}
+}
+
+// StatusCoder is implemented by errors that should be answered with a specific
+// status code by DefaultErrorHandler
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// StatusError is an error with the status code it should be answered with.  Err
+// is the optional underlying cause.  The Err* values can be wrapped with
+// fmt.Errorf and %w to add detail while keeping their status code
+type StatusError struct {
+	Code    int
+	Message string
+	Err     error
+}
+
+var (
+	ErrBadRequest   = NewStatusError(http.StatusBadRequest, "")
+	ErrUnauthorized = NewStatusError(http.StatusUnauthorized, "")
+	ErrForbidden    = NewStatusError(http.StatusForbidden, "")
+	ErrNotFound     = NewStatusError(http.StatusNotFound, "")
+	ErrConflict     = NewStatusError(http.StatusConflict, "")
+
+	ErrPreconditionFailed   = NewStatusError(http.StatusPreconditionFailed, "")
+	ErrPreconditionRequired = NewStatusError(http.StatusPreconditionRequired, "")
+	ErrUnsupportedMediaType = NewStatusError(http.StatusUnsupportedMediaType, "")
+)
+
+// NewStatusError returns a StatusError for the code.  An empty message defaults
+// to the status text of the code
+func NewStatusError(code int, message string) *StatusError {
+	if message == "" {
+		message = http.StatusText(code)
+	}
+
+	return &StatusError{Code: code, Message: message}
+}
+
+func (e *StatusError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StatusError) StatusCode() int {
+	return e.Code
+}
+
+// FieldError is a single invalid field of a ValidationError
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is answered with 422 Unprocessable Entity and lists every field
+// that failed
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Add appends an invalid field to the ValidationError
+func (e *ValidationError) Add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{field, message})
+}
+
+// Err returns the ValidationError if any field was added, otherwise nil
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// DefaultErrorHandler is used when a Handler has no ErrorHandler.  Errors that
+// implement StatusCoder are answered with their status code and message, any
+// other error is logged and answered with 500 Internal Server Error
+func DefaultErrorHandler(c *Context, err error) ResponseSender {
+	var sc StatusCoder
+	if errors.As(err, &sc) && sc.StatusCode() < http.StatusInternalServerError {
+		return BasicResponse{
+			StatusCode: sc.StatusCode(),
+			Headers:    map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+			Body:       []byte(err.Error()),
+		}
+	}
+
+	c.handler.logf("doze: %v %v: %v", c.Request.Method, c.Request.URL.Path, err)
+
+	status := http.StatusInternalServerError
+	if sc != nil {
+		status = sc.StatusCode()
+	}
+
+	return BasicResponse{
+		StatusCode: status,
+		Body:       []byte(http.StatusText(status)),
+	}
+}
@@ -0,0 +1,82 @@
+package doze
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingResponse struct{}
+
+func (f failingResponse) Send(w io.Writer) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestErrorActionStatusCodes(t *testing.T) {
+	validation := &ValidationError{}
+	validation.Add("name", "is required")
+
+	tests := []struct {
+		err  error
+		code int
+		body string
+	}{
+		{nil, http.StatusOK, `{"Message":"Simple Get"}`},
+		{ErrNotFound, http.StatusNotFound, "Not Found"},
+		{fmt.Errorf("user 7: %w", ErrConflict), http.StatusConflict, "user 7: Conflict"},
+		{NewStatusError(http.StatusPaymentRequired, "pay up"), http.StatusPaymentRequired, "pay up"},
+		{validation, http.StatusUnprocessableEntity, "validation failed: name: is required"},
+		{NewStatusError(http.StatusBadGateway, "upstream secret"), http.StatusBadGateway, "Bad Gateway"},
+		{errors.New("db password wrong"), http.StatusInternalServerError, "Internal Server Error"},
+	}
+
+	var logged bytes.Buffer
+
+	for i, test := range tests {
+		router := Router(fmt.Sprintf("TestErrorActionStatusCodes_%d", i))
+		router.Add(NewRoute().For("/test").With(http.MethodGet, ErrorAction(func(c *Context) (ResponseSender, error) {
+			if test.err != nil {
+				return nil, test.err
+			}
+
+			return TestController{}.SimpleGet(c), nil
+		})))
+
+		h := NewHandler(router)
+		h.ErrorLog = log.New(&logged, "", 0)
+
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+		assert.Equal(t, test.code, resp.Code, "they should be equal")
+		assert.Equal(t, test.body, resp.Body.String(), "they should be equal")
+	}
+
+	assert.Contains(t, logged.String(), "db password wrong", "internal errors should be logged")
+	assert.Contains(t, logged.String(), "upstream secret", "internal errors should be logged")
+}
+
+func TestSendFailureIsLogged(t *testing.T) {
+	router := Router("TestSendFailureIsLogged")
+	router.Add(NewRoute().For("/test").With(http.MethodGet, func(c *Context) ResponseSender {
+		return failingResponse{}
+	}))
+
+	var logged bytes.Buffer
+
+	h := NewHandler(router)
+	h.ErrorLog = log.New(&logged, "", 0)
+
+	assert.NotPanics(t, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+	}, "should not panic")
+
+	assert.Equal(t, "doze: GET /test: sending response: connection reset\n", logged.String(), "they should be equal")
+}
@@ -51,8 +51,17 @@ func (uc UserController) RedirectToUser(c *doze.Context) doze.ResponseSender {
 }
 
 // GetUser action maps to route /users/{id:i}
-func (uc UserController) GetUser(c *doze.Context) doze.ResponseSender {
-	return doze.NewOKJSONResponse(users[c.Route.Params()["id"].(int)-1])
+func (uc UserController) GetUser(c *doze.Context) (doze.ResponseSender, error) {
+	id, err := c.ParamInt("id")
+	if err != nil {
+		return nil, err
+	}
+
+	if id < 1 || id > len(users) {
+		return nil, fmt.Errorf("user %v: %w", id, doze.ErrNotFound)
+	}
+
+	return doze.NewOKJSONResponse(users[id-1]), nil
 }
 
 // GetAllUsers action maps to route /users (GET)
@@ -80,7 +89,7 @@ func main() {
 
 	router := doze.Router("api").SetPrefix(root)
 
-	router.Add(doze.NewRoute().Named("getUser").For("/users/{id:i}").With(http.MethodGet, userController.GetUser))
+	router.Add(doze.NewRoute().Named("getUser").For("/users/{id:i}").With(http.MethodGet, doze.ErrorAction(userController.GetUser)))
 	router.Add(doze.NewRoute().Named("redirectToUser").For("/users/{id:i}/{to:i}").With(http.MethodGet, userController.RedirectToUser))
 	router.Add(
 		doze.NewRoute().
@@ -88,10 +97,14 @@ func main() {
 			With(http.MethodGet, userController.GetAllUsers).
 			And(http.MethodPost, userController.CreateUser),
 	)
-	router.Add(doze.NewRoute().Named("protectedUser").For("/users/{id:i}/protected").With(http.MethodGet, userController.GetUser))
+
+	protected := router.Group("/users", requireAuth)
+	protected.Add(doze.NewRoute().Named("protectedUser").For("/{id:i}/protected").With(http.MethodGet, doze.ErrorAction(userController.GetUser)))
 
 	h := doze.NewHandler(router)
 
+	h.Use(doze.Recovery(nil))
+
 	// quick and dirty logging as an example
 	h.Use(func(ctx *doze.Context, next doze.NextFunc) {
 		start := time.Now()
@@ -106,8 +119,8 @@ func main() {
 
 		next(ctx)
 
-		httpStatus := ctx.ResponseWriter.StatusCode
-		contentLength := ctx.ResponseWriter.Size
+		httpStatus := ctx.ResponseWriter.Status()
+		contentLength := ctx.ResponseWriter.Size()
 
 		total := time.Since(start) * 1000
 
@@ -119,25 +132,22 @@ func main() {
 		fmt.Println(logStr)
 	})
 
-	h.Use(func(c *doze.Context, next doze.NextFunc) {
-		currentRoute := c.Route.Name()
-
-		if currentRoute == "protectedUser" {
-			token := c.Request.Header.Get("X-MyAuth")
+	http.Handle(router.Prefix()+"/", h)
 
-			if token != "letmein123" {
-				userForbidden(c)
+	log.Fatal(http.ListenAndServe(":8080", nil))
+}
 
-				return
-			}
-		}
+// requireAuth only runs for routes added to the protected group
+func requireAuth(c *doze.Context, next doze.NextFunc) {
+	token := c.Request.Header.Get("X-MyAuth")
 
-		next(c)
-	})
+	if token != "letmein123" {
+		userForbidden(c)
 
-	http.Handle(router.Prefix()+"/", h)
+		return
+	}
 
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	next(c)
 }
 
 func userForbidden(c *doze.Context) {
@@ -1,34 +1,356 @@
 package doze
 
-import "net/http"
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
 
 // ResponseWriter is a wrapper for http.ResponseWriter which includes extra properties
-// to keep track of current response
-type ResponseWriter struct {
+// to keep track of current response.
+//
+// It implements http.Flusher, http.Hijacker, http.Pusher and io.ReaderFrom
+// exactly when the http.ResponseWriter it wraps does, so type assertions
+// behave the same as on the wrapped writer.  Unwrap returns the wrapped writer
+// for http.ResponseController
+type ResponseWriter interface {
 	http.ResponseWriter
-	Size       int
-	StatusCode int
+
+	// Status returns the status code sent with the header, or 0 before it is sent
+	Status() int
+
+	// Size returns the number of bytes of the body written so far
+	Size() int
+
+	// Written returns whether or not the header of the current response has
+	// been sent, after which the status code and headers can no longer change
+	Written() bool
+
+	// BeforeWriteHeader registers a function to run just before the header is
+	// sent, so middleware can still change headers based on what the action
+	// did.  Hooks run in the order they were added and Status is already set
+	// when they do
+	BeforeWriteHeader(func())
+
+	// Request returns the request being responded to, so a ResponseSender can
+	// adapt what it sends.  It is nil when the ResponseWriter is used outside
+	// of a Handler
+	Request() *http.Request
+
+	// Unwrap returns the wrapped http.ResponseWriter
+	Unwrap() http.ResponseWriter
 }
 
-func (rw *ResponseWriter) Write(b []byte) (int, error) {
+// NewResponseWriter wraps w in a ResponseWriter that implements the same optional
+// interfaces as w does
+func NewResponseWriter(w http.ResponseWriter) ResponseWriter {
+	return (&responseWriter{ResponseWriter: w}).wrap()
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+
+	ctx         *Context
+	size        int
+	status      int
+	wroteHeader bool
+	beforeHooks []func()
+}
+
+func (rw *responseWriter) Status() int {
+	return rw.status
+}
+
+func (rw *responseWriter) Size() int {
+	return rw.size
+}
+
+func (rw *responseWriter) Request() *http.Request {
+	if rw.ctx == nil {
+		return nil
+	}
+
+	return rw.ctx.Request
+}
+
+// Write sends the header with a 200 status code first if it has not been sent yet
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	rw.WriteHeader(http.StatusOK)
+
 	size, err := rw.ResponseWriter.Write(b)
 
 	if err == nil {
-		rw.Size += size
+		rw.size += size
 	}
 
 	return size, err
 }
 
 // WriteHeader is overidding http.ResponseWriter in order to capture the
-// StatusCode of the request
-func (rw *ResponseWriter) WriteHeader(i int) {
+// status code of the request.  Only the first call sends the header, later calls
+// are ignored instead of being logged as superfluous by net/http.  Informational
+// 1xx status codes other than 101 are passed through without sending the header
+func (rw *responseWriter) WriteHeader(i int) {
+	if rw.wroteHeader {
+		return
+	}
+
+	if i >= 100 && i < 200 && i != http.StatusSwitchingProtocols {
+		rw.ResponseWriter.WriteHeader(i)
+		return
+	}
+
+	rw.wroteHeader = true
+	rw.status = i
+
+	for _, hook := range rw.beforeHooks {
+		hook()
+	}
+
 	rw.ResponseWriter.WriteHeader(i)
+}
 
-	rw.StatusCode = i
+func (rw *responseWriter) BeforeWriteHeader(hook func()) {
+	rw.beforeHooks = append(rw.beforeHooks, hook)
 }
 
-// Written returns whether or not the current response has been written to
-func (rw *ResponseWriter) Written() bool {
-	return rw.Size > 0
+func (rw *responseWriter) Written() bool {
+	return rw.wroteHeader
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// FlushError sends the header, if it has not been sent yet, and any buffered data
+// to the client.  It is used by http.ResponseController and returns an error
+// matching http.ErrNotSupported, without sending anything, when the wrapped
+// writer cannot flush
+func (rw *responseWriter) FlushError() error {
+	if !canFlush(rw.ResponseWriter) {
+		return fmt.Errorf("doze: flush: %w", http.ErrNotSupported)
+	}
+
+	rw.WriteHeader(http.StatusOK)
+
+	return http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+// hijack takes over the connection and marks the header as sent so nothing else
+// tries to write a response
+func (rw *responseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.wroteHeader = true
+	}
+
+	return conn, brw, err
+}
+
+// readFrom sends the header first and counts what is copied, using the wrapped
+// writer's ReadFrom when it still has one
+func (rw *responseWriter) readFrom(r io.Reader) (int64, error) {
+	rw.WriteHeader(http.StatusOK)
+
+	var n int64
+	var err error
+
+	if rf, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(rw.ResponseWriter, r)
+	}
+
+	rw.size += int(n)
+
+	return n, err
+}
+
+func (rw *responseWriter) response() *responseWriter {
+	return rw
+}
+
+// responseOf returns the responseWriter behind a ResponseWriter created by this
+// package, or nil for any other http.ResponseWriter
+func responseOf(w http.ResponseWriter) *responseWriter {
+	if r, ok := w.(interface{ response() *responseWriter }); ok {
+		return r.response()
+	}
+
+	return nil
+}
+
+type flusher struct{ rw *responseWriter }
+
+func (f flusher) Flush() {
+	f.rw.FlushError()
+}
+
+type hijacker struct{ rw *responseWriter }
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.rw.hijack()
+}
+
+type readerFrom struct{ rw *responseWriter }
+
+func (r readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	return r.rw.readFrom(src)
+}
+
+// wrap returns rw with the optional interfaces of the writer it wraps, the same
+// way github.com/felixge/httpsnoop does.  Flusher, Hijacker and Pusher are looked
+// for through Unwrap as well, while ReaderFrom has to be on the wrapped writer
+// itself as any wrapper in between may change what is written
+func (rw *responseWriter) wrap() ResponseWriter {
+	var (
+		f http.Flusher
+		h http.Hijacker
+		p http.Pusher
+		r io.ReaderFrom
+	)
+
+	if canFlush(rw.ResponseWriter) {
+		f = flusher{rw}
+	}
+
+	if findWriter(rw.ResponseWriter, func(w http.ResponseWriter) bool { _, ok := w.(http.Hijacker); return ok }) != nil {
+		h = hijacker{rw}
+	}
+
+	if w := findWriter(rw.ResponseWriter, func(w http.ResponseWriter) bool { _, ok := w.(http.Pusher); return ok }); w != nil {
+		p = w.(http.Pusher)
+	}
+
+	if _, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		r = readerFrom{rw}
+	}
+
+	switch {
+	case f == nil && h == nil && p == nil && r == nil:
+		return rw
+	case f != nil && h == nil && p == nil && r == nil:
+		return struct {
+			*responseWriter
+			http.Flusher
+		}{rw, f}
+	case f == nil && h != nil && p == nil && r == nil:
+		return struct {
+			*responseWriter
+			http.Hijacker
+		}{rw, h}
+	case f != nil && h != nil && p == nil && r == nil:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+		}{rw, f, h}
+	case f == nil && h == nil && p != nil && r == nil:
+		return struct {
+			*responseWriter
+			http.Pusher
+		}{rw, p}
+	case f != nil && h == nil && p != nil && r == nil:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Pusher
+		}{rw, f, p}
+	case f == nil && h != nil && p != nil && r == nil:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			http.Pusher
+		}{rw, h, p}
+	case f != nil && h != nil && p != nil && r == nil:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{rw, f, h, p}
+	case f == nil && h == nil && p == nil && r != nil:
+		return struct {
+			*responseWriter
+			io.ReaderFrom
+		}{rw, r}
+	case f != nil && h == nil && p == nil && r != nil:
+		return struct {
+			*responseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{rw, f, r}
+	case f == nil && h != nil && p == nil && r != nil:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, h, r}
+	case f != nil && h != nil && p == nil && r != nil:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{rw, f, h, r}
+	case f == nil && h == nil && p != nil && r != nil:
+		return struct {
+			*responseWriter
+			http.Pusher
+			io.ReaderFrom
+		}{rw, p, r}
+	case f != nil && h == nil && p != nil && r != nil:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Pusher
+			io.ReaderFrom
+		}{rw, f, p, r}
+	case f == nil && h != nil && p != nil && r != nil:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{rw, h, p, r}
+	default:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{rw, f, h, p, r}
+	}
+}
+
+// findWriter returns w or the first writer it wraps that match is true for, or
+// nil when there is none
+func findWriter(w http.ResponseWriter, match func(http.ResponseWriter) bool) http.ResponseWriter {
+	for {
+		if match(w) {
+			return w
+		}
+
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+
+		w = u.Unwrap()
+	}
+}
+
+// canFlush reports whether w or any writer it wraps can flush
+func canFlush(w http.ResponseWriter) bool {
+	return findWriter(w, func(w http.ResponseWriter) bool {
+		switch w.(type) {
+		case interface{ FlushError() error }, http.Flusher:
+			return true
+		}
+
+		return false
+	}) != nil
 }
@@ -0,0 +1,242 @@
+package doze
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// plainResponseWriter supports none of the optional interfaces
+type plainResponseWriter struct {
+	header http.Header
+	body   strings.Builder
+}
+
+func (p *plainResponseWriter) Header() http.Header {
+	return p.header
+}
+
+func (p *plainResponseWriter) Write(b []byte) (int, error) {
+	return p.body.Write(b)
+}
+
+func (p *plainResponseWriter) WriteHeader(int) {}
+
+// fullResponseWriter supports all of the optional interfaces
+type fullResponseWriter struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (f *fullResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("not a connection")
+}
+
+func (f *fullResponseWriter) Push(target string, opts *http.PushOptions) error {
+	f.pushed = append(f.pushed, target)
+	return nil
+}
+
+func (f *fullResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(f.ResponseRecorder, r)
+}
+
+func optionalInterfaces(w http.ResponseWriter) []string {
+	var supported []string
+
+	if _, ok := w.(http.Flusher); ok {
+		supported = append(supported, "Flusher")
+	}
+	if _, ok := w.(http.Hijacker); ok {
+		supported = append(supported, "Hijacker")
+	}
+	if _, ok := w.(http.Pusher); ok {
+		supported = append(supported, "Pusher")
+	}
+	if _, ok := w.(io.ReaderFrom); ok {
+		supported = append(supported, "ReaderFrom")
+	}
+
+	return supported
+}
+
+func TestResponseWriterOptionalInterfaces(t *testing.T) {
+	plain := &plainResponseWriter{header: make(http.Header)}
+	full := &fullResponseWriter{ResponseRecorder: httptest.NewRecorder()}
+
+	assert.Equal(t, []string{"Flusher"}, optionalInterfaces(NewResponseWriter(httptest.NewRecorder())), "they should be equal")
+	assert.Empty(t, optionalInterfaces(NewResponseWriter(plain)), "should be empty")
+	assert.Equal(t, []string{"Flusher", "Hijacker", "Pusher", "ReaderFrom"}, optionalInterfaces(NewResponseWriter(full)), "they should be equal")
+
+	head := NewResponseWriter(headResponseWriter{full})
+
+	assert.Equal(t, []string{"Flusher", "Hijacker", "Pusher"}, optionalInterfaces(head), "ReaderFrom should not skip the HEAD wrapper")
+
+	w := NewResponseWriter(full)
+
+	w.(http.Pusher).Push("/app.js", nil)
+	n, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("read"))
+
+	assert.Equal(t, []string{"/app.js"}, full.pushed, "they should be equal")
+	assert.Nil(t, err, "error should be nil")
+	assert.Equal(t, int64(4), n, "they should be equal")
+	assert.Equal(t, 4, w.Size(), "they should be equal")
+	assert.Equal(t, http.StatusOK, w.Status(), "reading should send the header")
+	assert.Equal(t, "read", full.Body.String(), "they should be equal")
+}
+
+func TestResponseWriterUnsupported(t *testing.T) {
+	plain := &plainResponseWriter{header: make(http.Header)}
+	rw := NewResponseWriter(plain)
+	rc := http.NewResponseController(rw)
+
+	_, _, err := rc.Hijack()
+	assert.True(t, errors.Is(err, http.ErrNotSupported), "hijack should not be supported")
+
+	assert.True(t, errors.Is(rc.Flush(), http.ErrNotSupported), "flush should not be supported")
+	assert.False(t, rw.Written(), "an unsupported flush should not send the header")
+
+	n, err := io.Copy(rw, strings.NewReader("copied"))
+
+	assert.Nil(t, err, "error should be nil")
+	assert.Equal(t, int64(6), n, "they should be equal")
+	assert.Equal(t, 6, rw.Size(), "they should be equal")
+	assert.Equal(t, "copied", plain.body.String(), "they should be equal")
+}
+
+func TestResponseWriterHttptest(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	rw := NewResponseWriter(recorder)
+
+	rw.Write([]byte("data"))
+	rw.(http.Flusher).Flush()
+
+	assert.True(t, recorder.Flushed, "recorder should be flushed")
+
+	recorder = httptest.NewRecorder()
+	rw = NewResponseWriter(recorder)
+
+	assert.Nil(t, http.NewResponseController(rw).Flush(), "error should be nil")
+	assert.True(t, recorder.Flushed, "recorder should be flushed")
+	assert.True(t, rw.Written(), "flushing should send the header")
+
+	_, _, err := http.NewResponseController(rw).Hijack()
+	assert.True(t, errors.Is(err, http.ErrNotSupported), "recorder cannot be hijacked")
+}
+
+func TestResponseWriterRealServer(t *testing.T) {
+	type result struct {
+		supported []string
+		written   bool
+		size      int
+		err       error
+	}
+
+	results := make(chan result, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := NewResponseWriter(w)
+		res := result{supported: optionalInterfaces(rw)}
+
+		if r.URL.Path == "/hijack" {
+			conn, brw, err := rw.(http.Hijacker).Hijack()
+			res.err = err
+
+			brw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+			brw.Flush()
+			conn.Close()
+		} else {
+			_, res.err = rw.(io.ReaderFrom).ReadFrom(strings.NewReader("streamed"))
+			rw.(http.Flusher).Flush()
+		}
+
+		res.written = rw.Written()
+		res.size = rw.Size()
+
+		results <- res
+	}))
+	defer server.Close()
+
+	resp, _ := http.Get(server.URL + "/stream")
+	body, _ := ioutil.ReadAll(resp.Body)
+	res := <-results
+
+	assert.Equal(t, "streamed", string(body), "they should be equal")
+	assert.Equal(t, []string{"Flusher", "Hijacker", "ReaderFrom"}, res.supported, "push is not supported over HTTP/1.1")
+	assert.Nil(t, res.err, "error should be nil")
+	assert.Equal(t, 8, res.size, "they should be equal")
+
+	resp, _ = http.Get(server.URL + "/hijack")
+	line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+	res = <-results
+
+	assert.Nil(t, res.err, "error should be nil")
+	assert.True(t, res.written, "a hijacked response should count as written")
+	assert.Equal(t, "hijacked", line, "they should be equal")
+}
+
+func TestResponseWriterHeaderTracking(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	rw := NewResponseWriter(recorder)
+
+	assert.False(t, rw.Written(), "should not be written")
+
+	rw.WriteHeader(http.StatusNoContent)
+
+	assert.True(t, rw.Written(), "header only response should be written")
+	assert.Equal(t, 0, rw.Size(), "they should be equal")
+
+	rw.WriteHeader(http.StatusInternalServerError)
+
+	assert.Equal(t, http.StatusNoContent, rw.Status(), "superfluous WriteHeader should be ignored")
+	assert.Equal(t, http.StatusNoContent, recorder.Code, "superfluous WriteHeader should be ignored")
+}
+
+func TestResponseWriterImplicitStatus(t *testing.T) {
+	rw := NewResponseWriter(httptest.NewRecorder())
+
+	rw.Write([]byte("hello"))
+
+	assert.True(t, rw.Written(), "should be written")
+	assert.Equal(t, http.StatusOK, rw.Status(), "they should be equal")
+	assert.Equal(t, 5, rw.Size(), "they should be equal")
+
+	rw = NewResponseWriter(httptest.NewRecorder())
+	http.NewResponseController(rw).Flush()
+
+	assert.Equal(t, http.StatusOK, rw.Status(), "flushing should send the header")
+}
+
+func TestResponseWriterBeforeWriteHeader(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	rw := NewResponseWriter(recorder)
+
+	var calls []string
+
+	rw.BeforeWriteHeader(func() {
+		calls = append(calls, "first")
+		rw.Header().Set("X-Status", http.StatusText(rw.Status()))
+	})
+	rw.BeforeWriteHeader(func() {
+		calls = append(calls, "second")
+	})
+
+	rw.WriteHeader(http.StatusContinue)
+
+	assert.False(t, rw.Written(), "1xx should not send the header")
+	assert.Empty(t, calls, "hooks should not run for 1xx")
+
+	rw.WriteHeader(http.StatusCreated)
+	rw.Write([]byte("created"))
+
+	assert.Equal(t, []string{"first", "second"}, calls, "hooks should run once in order")
+	assert.Equal(t, "Created", recorder.Header().Get("X-Status"), "hooks should be able to set headers")
+}
@@ -0,0 +1,177 @@
+package doze
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxJSONBytes is the largest JSON body read when JSONOptions.MaxBytes is 0
+const DefaultMaxJSONBytes = 1 << 20
+
+// JSONOptions configures how BindJSONEntity and Bind decode JSON request bodies
+type JSONOptions struct {
+	// Strict rejects fields the value has no place for and anything after the
+	// first JSON value
+	Strict bool
+
+	// MaxBytes is the largest body in bytes that is read, DefaultMaxJSONBytes
+	// when 0 and unlimited when negative
+	MaxBytes int64
+}
+
+// JSONSyntaxError is returned when the body is not a single well formed JSON
+// value, including when it is empty.  It is answered with 400 Bad Request
+type JSONSyntaxError struct {
+	Offset  int64
+	Message string
+	Err     error
+}
+
+func (e *JSONSyntaxError) Error() string {
+	return fmt.Sprintf("invalid JSON at offset %d: %v", e.Offset, e.Message)
+}
+
+func (e *JSONSyntaxError) Unwrap() error {
+	return e.Err
+}
+
+func (e *JSONSyntaxError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// JSONTypeError is returned when a JSON value does not fit the Go type of the
+// field it is decoded into, such as a string for an int.  It is answered with
+// 400 Bad Request
+type JSONTypeError struct {
+	Field    string
+	Expected string
+	Got      string
+	Offset   int64
+}
+
+func (e *JSONTypeError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("expected %v, got %v", e.Expected, e.Got)
+	}
+
+	return fmt.Sprintf("field %q: expected %v, got %v", e.Field, e.Expected, e.Got)
+}
+
+func (e *JSONTypeError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// JSONUnknownFieldError is returned in strict mode for a field the value has no
+// place for.  It is answered with 400 Bad Request
+type JSONUnknownFieldError struct {
+	Field string
+}
+
+func (e *JSONUnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q", e.Field)
+}
+
+func (e *JSONUnknownFieldError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// BodyTooLargeError is returned when the body is larger than the limit.  It is
+// answered with 413 Request Entity Too Large
+type BodyTooLargeError struct {
+	Limit int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body is larger than %d bytes", e.Limit)
+}
+
+func (e *BodyTooLargeError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// BindJSONEntityWith is like BindJSONEntity but uses opts instead of the
+// Handler's JSONOptions
+func (c *Context) BindJSONEntityWith(i interface{}, opts JSONOptions) error {
+	if ct := c.Request.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || !isJSONMediaType(mediaType) {
+			return ErrUnsupportedMediaType
+		}
+	}
+
+	return c.decodeJSON(i, opts)
+}
+
+func (c *Context) jsonOptions() JSONOptions {
+	if c.handler == nil {
+		return JSONOptions{}
+	}
+
+	return c.handler.JSON
+}
+
+func (c *Context) decodeJSON(i interface{}, opts JSONOptions) error {
+	limit := opts.MaxBytes
+	if limit == 0 {
+		limit = DefaultMaxJSONBytes
+	}
+
+	body := c.Request.Body
+	if limit > 0 {
+		body = http.MaxBytesReader(c.ResponseWriter, body, limit)
+	}
+
+	dec := json.NewDecoder(body)
+	if opts.Strict {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(i); err != nil {
+		return jsonError(err, dec)
+	}
+
+	if opts.Strict {
+		if _, err := dec.Token(); err == nil {
+			return &JSONSyntaxError{Offset: dec.InputOffset(), Message: "unexpected data after JSON value"}
+		} else if err != io.EOF {
+			return jsonError(err, dec)
+		}
+	}
+
+	return nil
+}
+
+// jsonError turns the errors of encoding/json and http.MaxBytesReader into the
+// typed errors above.  Anything else, such as decoding into a non-pointer, is a
+// mistake in the action and returned as it is
+func jsonError(err error, dec *json.Decoder) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		sizeErr   *http.MaxBytesError
+	)
+
+	switch {
+	case errors.As(err, &sizeErr):
+		return &BodyTooLargeError{Limit: sizeErr.Limit}
+	case err == io.EOF:
+		return &JSONSyntaxError{Message: "empty body", Err: err}
+	case err == io.ErrUnexpectedEOF:
+		// the decoder does not advance past a value it could not finish
+		n, _ := io.Copy(io.Discard, dec.Buffered())
+		return &JSONSyntaxError{Offset: dec.InputOffset() + n, Message: "unexpected end of JSON", Err: err}
+	case errors.As(err, &syntaxErr):
+		return &JSONSyntaxError{Offset: syntaxErr.Offset, Message: strings.TrimPrefix(syntaxErr.Error(), "json: "), Err: err}
+	case errors.As(err, &typeErr):
+		return &JSONTypeError{Field: typeErr.Field, Expected: typeErr.Type.String(), Got: typeErr.Value, Offset: typeErr.Offset}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return &JSONUnknownFieldError{Field: strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)}
+	}
+
+	return err
+}
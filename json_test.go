@@ -0,0 +1,111 @@
+package doze
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type JSONTestUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func bindJSON(opts JSONOptions, contentType, body string) (JSONTestUser, error) {
+	var (
+		user JSONTestUser
+		err  error
+	)
+
+	router := Router("bindJSON")
+	router.Add(NewRoute().For("/users").With(http.MethodPost, func(c *Context) ResponseSender {
+		err = c.BindJSONEntity(&user)
+		return NewNoContentResponse()
+	}))
+
+	h := NewHandler(router)
+	h.JSON = opts
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	return user, err
+}
+
+func TestBindJSONEntity(t *testing.T) {
+	user, err := bindJSON(JSONOptions{}, "application/json", `{"name": "Mary", "age": 30, "extra": true} {}`)
+
+	assert.Nil(t, err, "should be nil")
+	assert.Equal(t, JSONTestUser{"Mary", 30}, user, "they should be equal")
+
+	user, err = bindJSON(JSONOptions{}, "", `{"name": "Mary"}`)
+
+	assert.Nil(t, err, "should be nil")
+	assert.Equal(t, "Mary", user.Name, "they should be equal")
+
+	_, err = bindJSON(JSONOptions{}, "application/vnd.api+json; charset=utf-8", `{"name": "Mary"}`)
+
+	assert.Nil(t, err, "should be nil")
+}
+
+func TestBindJSONEntityErrors(t *testing.T) {
+	strict := JSONOptions{Strict: true, MaxBytes: 32}
+
+	tests := []struct {
+		opts        JSONOptions
+		contentType string
+		body        string
+		err         error
+		code        int
+	}{
+		{JSONOptions{}, "text/plain", `{"name": "Mary"}`, ErrUnsupportedMediaType, http.StatusUnsupportedMediaType},
+		{JSONOptions{}, "application/json", `{"name": "Mary"`, &JSONSyntaxError{Offset: 15, Message: "unexpected end of JSON"}, http.StatusBadRequest},
+		{JSONOptions{}, "application/json", `{"name" "Mary"}`, &JSONSyntaxError{Offset: 9, Message: "invalid character '\"' after object key"}, http.StatusBadRequest},
+		{JSONOptions{}, "application/json", ``, &JSONSyntaxError{Message: "empty body"}, http.StatusBadRequest},
+		{JSONOptions{}, "application/json", `{"age": "thirty"}`, &JSONTypeError{Field: "age", Expected: "int", Got: "string", Offset: 16}, http.StatusBadRequest},
+		{strict, "application/json", `{"name": "Mary", "admin": true}`, &JSONUnknownFieldError{Field: "admin"}, http.StatusBadRequest},
+		{strict, "application/json", `{"name": "Mary"} {}`, &JSONSyntaxError{Offset: 18, Message: "unexpected data after JSON value"}, http.StatusBadRequest},
+		{strict, "application/json", `{"name": "` + strings.Repeat("a", 40) + `"}`, &BodyTooLargeError{Limit: 32}, http.StatusRequestEntityTooLarge},
+		{JSONOptions{}, "application/json", `{"name": "` + strings.Repeat("a", DefaultMaxJSONBytes) + `"}`, &BodyTooLargeError{Limit: DefaultMaxJSONBytes}, http.StatusRequestEntityTooLarge},
+	}
+
+	for i, test := range tests {
+		_, err := bindJSON(test.opts, test.contentType, test.body)
+
+		msg := fmt.Sprintf("they should be equal for test %v", i)
+
+		if se, ok := err.(*JSONSyntaxError); ok {
+			se.Err = nil
+		}
+
+		assert.Equal(t, test.err, err, msg)
+
+		var sc StatusCoder
+
+		assert.True(t, errors.As(err, &sc), msg)
+		assert.Equal(t, test.code, sc.StatusCode(), msg)
+	}
+
+	_, err := bindJSON(JSONOptions{MaxBytes: -1}, "application/json", `{"name": "`+strings.Repeat("a", DefaultMaxJSONBytes)+`"}`)
+
+	assert.Nil(t, err, "should be nil")
+}
+
+func TestBindOversizedJSON(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/users/42/007", strings.NewReader(`{"name": "`+strings.Repeat("a", DefaultMaxJSONBytes)+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	var br BindTestRequest
+	err := bindRequest(req, &br)
+
+	assert.Equal(t, &BodyTooLargeError{Limit: DefaultMaxJSONBytes}, err, "they should be equal")
+}
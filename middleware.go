@@ -63,11 +63,16 @@ func buildChain(ctx *Context, m *middleware, action ActionFunc) NextFunc {
 func doAction(ctx *Context, action ActionFunc) {
 	result := action(ctx)
 
+	if er, ok := result.(errorResponse); ok {
+		result = ctx.handler.handleError(ctx, er.err)
+	}
+
 	if result != nil {
 		_, err := result.Send(ctx.ResponseWriter)
 
+		// the response is already partly written so all that is left is to log it
 		if err != nil {
-			panic(err)
+			ctx.handler.logf("doze: %v %v: sending response: %v", ctx.Request.Method, ctx.Request.URL.Path, err)
 		}
 	}
 }
@@ -1,6 +1,10 @@
 package doze
 
 import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
@@ -13,7 +17,7 @@ func TestMiddlewareChain(t *testing.T) {
 
 	ctx := &Context{
 		Request:        httptest.NewRequest("GET", "http://test", nil),
-		ResponseWriter: &ResponseWriter{response, 0, 0},
+		ResponseWriter: NewResponseWriter(response),
 	}
 
 	mwc := &middlewareChain{
@@ -59,3 +63,65 @@ func stubActionFunc(ctx *Context) ResponseSender {
 
 	return NewOKJSONResponse(result)
 }
+
+func TestRecovery(t *testing.T) {
+	router := Router("TestRecovery")
+	router.Add(NewRoute().Named("boom").For("/boom").With("GET", func(c *Context) ResponseSender {
+		panic("boom")
+	}))
+	router.Add(NewRoute().Named("partial").For("/partial").With("GET", func(c *Context) ResponseSender {
+		c.ResponseWriter.WriteHeader(http.StatusAccepted)
+		c.ResponseWriter.Write([]byte("partial"))
+
+		panic("too late")
+	}))
+
+	var logged bytes.Buffer
+	var recovered *Panic
+
+	h := NewHandler(router)
+	h.ErrorLog = log.New(&logged, "", 0)
+	h.Use(Recovery(func(c *Context, p *Panic) ResponseSender {
+		recovered = p
+
+		return BasicResponse{StatusCode: http.StatusInternalServerError, Body: []byte(`{"error":"internal"}`)}
+	}))
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest("GET", "/boom", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code, "they should be equal")
+	assert.Equal(t, `{"error":"internal"}`, resp.Body.String(), "they should be equal")
+	assert.Equal(t, "boom", recovered.Value, "they should be equal")
+	assert.Equal(t, "boom", recovered.Route, "they should be equal")
+	assert.Contains(t, string(recovered.Stack), "TestRecovery", "stack should be recorded")
+	assert.Contains(t, logged.String(), `panic in route "boom": boom`, "panic should be logged")
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest("GET", "/partial", nil))
+
+	assert.Equal(t, http.StatusAccepted, resp.Code, "written response should be kept")
+	assert.Equal(t, "partial", resp.Body.String(), "written response should be kept")
+}
+
+func TestRecoveryDefaultResponse(t *testing.T) {
+	ctx := &Context{
+		Request:        httptest.NewRequest("GET", "http://test", nil),
+		ResponseWriter: NewResponseWriter(httptest.NewRecorder()),
+		Route:          PatternedRoute{Route: NewRoute()},
+		handler:        &Handler{ErrorLog: log.New(ioutil.Discard, "", 0)},
+	}
+
+	mwc := &middlewareChain{
+		action: func(c *Context) ResponseSender {
+			var m map[string]int
+			m["nil"] = 1
+
+			return nil
+		},
+	}
+	mwc.add(Recovery(nil))
+
+	assert.NotPanics(t, func() { mwc.run(ctx) }, "should not panic")
+	assert.Equal(t, http.StatusInternalServerError, ctx.ResponseWriter.Status(), "they should be equal")
+}
@@ -0,0 +1,277 @@
+package doze
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EncoderFunc writes a value to w in the media type it is registered for
+type EncoderFunc func(w io.Writer, v interface{}) error
+
+type encoder struct {
+	mediaType string
+	fn        EncoderFunc
+}
+
+var (
+	encoders    []encoder
+	encoderLock sync.RWMutex
+)
+
+func init() {
+	RegisterEncoder("application/json", encodeJSON)
+	RegisterEncoder("application/xml", encodeXML)
+	RegisterEncoder("text/xml", encodeXML)
+	RegisterEncoder("text/plain", encodeText)
+}
+
+// RegisterEncoder makes a media type available to NegotiatedResponse.  Media
+// types are preferred in the order they are registered when the client has no
+// preference, and registering a media type again replaces its encoder
+func RegisterEncoder(mediaType string, fn EncoderFunc) {
+	encoderLock.Lock()
+	defer encoderLock.Unlock()
+
+	mediaType = strings.ToLower(mediaType)
+
+	for i, e := range encoders {
+		if e.mediaType == mediaType {
+			encoders[i].fn = fn
+			return
+		}
+	}
+
+	encoders = append(encoders, encoder{mediaType, fn})
+}
+
+// NegotiatedResponse holds a value which is encoded when it is sent, in the
+// registered media type that best fits the request's Accept header.  When no
+// registered media type is acceptable 406 Not Acceptable is sent instead
+type NegotiatedResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Value      interface{}
+}
+
+// NewNegotiatedResponse returns a NegotiatedResponse for the value and status code
+func NewNegotiatedResponse(statusCode int, v interface{}) NegotiatedResponse {
+	return NegotiatedResponse{StatusCode: statusCode, Value: v}
+}
+
+// NewOKNegotiatedResponse returns a NegotiatedResponse with status code of 200
+func NewOKNegotiatedResponse(v interface{}) NegotiatedResponse {
+	return NewNegotiatedResponse(http.StatusOK, v)
+}
+
+// Send encodes the value for the request and writes it to the http.ResponseWriter.
+// When the value cannot be encoded in the preferred media type the next
+// acceptable one is tried, and 500 Internal Server Error is sent if none works
+func (nr NegotiatedResponse) Send(w io.Writer) (int, error) {
+	var accept string
+	if rw, ok := w.(ResponseWriter); ok {
+		rw.Header().Add("Vary", "Accept")
+
+		if r := rw.Request(); r != nil {
+			accept = r.Header.Get("Accept")
+		}
+	}
+
+	encs := negotiate(accept)
+	if len(encs) == 0 {
+		return BasicResponse{
+			StatusCode: http.StatusNotAcceptable,
+			Body:       []byte(http.StatusText(http.StatusNotAcceptable)),
+		}.Send(w)
+	}
+
+	// fall back to the next acceptable media type if the value cannot be
+	// encoded in the preferred one, such as a map as XML
+	var (
+		body bytes.Buffer
+		enc  encoder
+		err  error
+	)
+
+	for _, enc = range encs {
+		body.Reset()
+
+		if err = enc.fn(&body, nr.Value); err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		n, _ := BasicResponse{
+			StatusCode: http.StatusInternalServerError,
+			Headers:    map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+			Body:       []byte(http.StatusText(http.StatusInternalServerError)),
+		}.Send(w)
+		return n, fmt.Errorf("encoding %v: %w", enc.mediaType, err)
+	}
+
+	contentType := enc.mediaType
+	if strings.HasPrefix(contentType, "text/") {
+		contentType += "; charset=utf-8"
+	}
+
+	headers := map[string]string{"Content-Type": contentType}
+	for k, v := range nr.Headers {
+		headers[k] = v
+	}
+
+	return BasicResponse{StatusCode: nr.StatusCode, Headers: headers, Body: body.Bytes()}.Send(w)
+}
+
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// specificity ranks exact types above type/* above */*
+func (mr mediaRange) specificity() int {
+	switch {
+	case mr.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(mr.mediaType, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (mr mediaRange) matches(mediaType string) bool {
+	switch mr.specificity() {
+	case 0:
+		return true
+	case 1:
+		return strings.HasPrefix(mediaType, mr.mediaType[:len(mr.mediaType)-1])
+	default:
+		return mr.mediaType == mediaType
+	}
+}
+
+// negotiate returns the encoders acceptable for an Accept header, most preferred
+// first.  An empty header accepts anything, so every encoder is returned in the
+// order they were registered
+func negotiate(accept string) []encoder {
+	encoderLock.RLock()
+	defer encoderLock.RUnlock()
+
+	if strings.TrimSpace(accept) == "" {
+		return append([]encoder(nil), encoders...)
+	}
+
+	ranges := parseAccept(accept)
+
+	var encs []encoder
+	seen := make(map[string]bool, len(encoders))
+
+	// an encoder is only acceptable if the most specific range matching it
+	// does not have q=0
+	for _, mr := range ranges {
+		if mr.q == 0 {
+			continue
+		}
+
+		for _, enc := range encoders {
+			if !seen[enc.mediaType] && mr.matches(enc.mediaType) && acceptable(ranges, enc.mediaType) {
+				seen[enc.mediaType] = true
+				encs = append(encs, enc)
+			}
+		}
+	}
+
+	return encs
+}
+
+func acceptable(ranges []mediaRange, mediaType string) bool {
+	best := -1
+	q := 0.0
+
+	for _, mr := range ranges {
+		if mr.matches(mediaType) && mr.specificity() > best {
+			best = mr.specificity()
+			q = mr.q
+		}
+	}
+
+	return q > 0
+}
+
+// parseAccept returns the media ranges of an Accept header ordered by preference
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType, q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+
+	return ranges
+}
+
+func encodeJSON(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+
+	return err
+}
+
+func encodeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func encodeText(w io.Writer, v interface{}) error {
+	var err error
+
+	switch t := v.(type) {
+	case []byte:
+		_, err = w.Write(t)
+	case encoding.TextMarshaler:
+		var b []byte
+		if b, err = t.MarshalText(); err == nil {
+			_, err = w.Write(b)
+		}
+	default:
+		_, err = fmt.Fprint(w, v)
+	}
+
+	return err
+}
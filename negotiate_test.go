@@ -0,0 +1,114 @@
+package doze
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type negotiatedUser struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func (u negotiatedUser) String() string {
+	return "user " + u.Name
+}
+
+func TestNegotiatedResponse(t *testing.T) {
+	RegisterEncoder("text/csv", func(w io.Writer, v interface{}) error {
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name", v.(negotiatedUser).Name})
+		cw.Flush()
+
+		return cw.Error()
+	})
+
+	router := Router("TestNegotiatedResponse")
+	router.Add(NewRoute().For("/user").With(http.MethodGet, func(c *Context) ResponseSender {
+		return NewOKNegotiatedResponse(negotiatedUser{"Joe"})
+	}))
+
+	h := NewHandler(router)
+
+	tests := []struct {
+		accept, contentType, body string
+		code                      int
+	}{
+		{"", "application/json", `{"name":"Joe"}`, http.StatusOK},
+		{"*/*", "application/json", `{"name":"Joe"}`, http.StatusOK},
+		{"application/xml", "application/xml", `<?xml version="1.0" encoding="UTF-8"?>` + "\n<negotiatedUser><name>Joe</name></negotiatedUser>", http.StatusOK},
+		{"text/plain", "text/plain; charset=utf-8", "user Joe", http.StatusOK},
+		{"text/csv", "text/csv; charset=utf-8", "name,Joe\n", http.StatusOK},
+		{"application/json;q=0.5, text/plain", "text/plain; charset=utf-8", "user Joe", http.StatusOK},
+		{"text/*;q=0.9, application/json;q=0.1", "text/xml; charset=utf-8", `<?xml version="1.0" encoding="UTF-8"?>` + "\n<negotiatedUser><name>Joe</name></negotiatedUser>", http.StatusOK},
+		{"*/*, application/json;q=0", "application/xml", `<?xml version="1.0" encoding="UTF-8"?>` + "\n<negotiatedUser><name>Joe</name></negotiatedUser>", http.StatusOK},
+		{"image/png", "", "Not Acceptable", http.StatusNotAcceptable},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		req.Header.Set("Accept", test.accept)
+
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		msg := fmt.Sprintf("they should be equal for Accept %q", test.accept)
+
+		assert.Equal(t, test.code, resp.Code, msg)
+		assert.Equal(t, test.body, resp.Body.String(), msg)
+		assert.Equal(t, "Accept", resp.Header().Get("Vary"), msg)
+
+		if test.contentType != "" {
+			assert.Equal(t, test.contentType, resp.Header().Get("Content-Type"), msg)
+		}
+	}
+}
+
+func TestNegotiatedResponseEncodingFails(t *testing.T) {
+	router := Router("TestNegotiatedResponseEncodingFails")
+	router.Add(NewRoute().For("/map").With(http.MethodGet, func(c *Context) ResponseSender {
+		return NewOKNegotiatedResponse(map[string]interface{}{"name": "Joe"})
+	}))
+	router.Add(NewRoute().For("/func").With(http.MethodGet, func(c *Context) ResponseSender {
+		return NewOKNegotiatedResponse(func() {})
+	}))
+
+	var logged bytes.Buffer
+
+	h := NewHandler(router)
+	h.ErrorLog = log.New(&logged, "", 0)
+
+	tests := []struct {
+		path, accept, contentType, body string
+		code                            int
+	}{
+		// XML cannot encode a map, so the next acceptable type is used
+		{"/map", "application/xml, application/json;q=0.5", "application/json", `{"name":"Joe"}`, http.StatusOK},
+		{"/map", "application/xml", "text/plain; charset=utf-8", "Internal Server Error", http.StatusInternalServerError},
+		{"/func", "application/json", "text/plain; charset=utf-8", "Internal Server Error", http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.Header.Set("Accept", test.accept)
+
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		msg := fmt.Sprintf("they should be equal for %v %v", test.path, test.accept)
+
+		assert.Equal(t, test.code, resp.Code, msg)
+		assert.Equal(t, test.contentType, resp.Header().Get("Content-Type"), msg)
+		assert.Equal(t, test.body, strings.TrimSpace(resp.Body.String()), msg)
+	}
+
+	assert.Contains(t, logged.String(), "encoding application/xml: xml: unsupported type", "encoding errors should be logged")
+}
@@ -0,0 +1,265 @@
+package doze
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ParamParser converts the text matched by a typed route param into its value
+type ParamParser func(string) (interface{}, error)
+
+type paramType struct {
+	pattern string
+	matcher *regexp.Regexp
+	parse   ParamParser
+}
+
+var (
+	paramTypes     = make(map[string]*paramType)
+	paramTypesLock sync.RWMutex
+
+	paramTypeName = regexp.MustCompile(`^\w+$`)
+)
+
+func init() {
+	RegisterParamType(intParam, `[0-9]+`, func(s string) (interface{}, error) { return strconv.Atoi(s) })
+	RegisterParamType(alphaParam, `[A-Za-z]+`, nil)
+	RegisterParamType(alphaNumParam, `[0-9A-Za-z]+`, nil)
+	RegisterParamType("int64", `-?[0-9]+`, func(s string) (interface{}, error) { return strconv.ParseInt(s, 10, 64) })
+	RegisterParamType("uuid", `[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}`, func(s string) (interface{}, error) { return ParseUUID(s) })
+	RegisterParamType("slug", `[a-z0-9]+(?:-[a-z0-9]+)*`, nil)
+	RegisterParamType("date", `[0-9]{4}-[0-9]{2}-[0-9]{2}`, func(s string) (interface{}, error) { return time.Parse(dateLayout, s) })
+	RegisterParamType("hex", `[0-9A-Fa-f]+`, nil)
+}
+
+const dateLayout = "2006-01-02"
+
+// RegisterParamType makes {name:type} available in route paths.  A value matches
+// the type when the whole of it matches pattern and parse, if given, accepts it.
+// PatternedRoute.Params returns what parse returns, or the text itself when
+// parse is nil.  The built in types are:
+//
+//	i      an int
+//	int64  a signed int64
+//	a      letters, as a string
+//	an     letters and digits, as a string
+//	uuid   a UUID such as 123e4567-e89b-12d3-a456-426614174000, as a UUID
+//	slug   lowercase words joined by dashes, as a string
+//	date   a date such as 2024-03-01, as a time.Time
+//	hex    hex digits, as a string
+//
+// Registering a type again replaces it for routes added afterwards, routes that
+// were already added keep matching and parsing with the type they were added
+// with.  It panics if name is not
+// made of word characters or pattern does not compile
+func RegisterParamType(name, pattern string, parse ParamParser) {
+	if !paramTypeName.MatchString(name) {
+		panic(fmt.Sprintf("doze: invalid param type name %q", name))
+	}
+
+	pt := &paramType{
+		pattern: pattern,
+		matcher: regexp.MustCompile(`^(?:` + pattern + `)$`),
+		parse:   parse,
+	}
+
+	paramTypesLock.Lock()
+	defer paramTypesLock.Unlock()
+
+	paramTypes[name] = pt
+}
+
+// lookupParamType returns the registered type, or nil when the type is unknown
+// and the param should match any segment
+func lookupParamType(name string) *paramType {
+	paramTypesLock.RLock()
+	defer paramTypesLock.RUnlock()
+
+	return paramTypes[name]
+}
+
+func (pt *paramType) match(s string) bool {
+	return pt.matcher.MatchString(s)
+}
+
+// parses reports whether the parser, if any, accepts a value that already matched
+func (pt *paramType) parses(s string) bool {
+	if pt.parse == nil {
+		return true
+	}
+
+	_, err := pt.parse(s)
+
+	return err == nil
+}
+
+// value returns the parsed value of a param of this type
+func (pt *paramType) value(s string) interface{} {
+	if pt == nil || pt.parse == nil {
+		return s
+	}
+
+	v, err := pt.parse(s)
+	if err != nil {
+		return s
+	}
+
+	return v
+}
+
+// resolveParamTypes returns the registered type of every param in a route path,
+// nil for untyped params and unknown types
+func resolveParamTypes(path string) []*paramType {
+	toSub := regParam.FindAllStringSubmatch(path, -1)
+
+	types := make([]*paramType, len(toSub))
+	for i, v := range toSub {
+		if v[2] != "" && v[2] != ":"+catchAllParam {
+			types[i] = lookupParamType(v[2][1:])
+		}
+	}
+
+	return types
+}
+
+// UUID is the value of a uuid route param
+type UUID [16]byte
+
+// ParseUUID parses a UUID in its canonical 8-4-4-4-12 hex digit form
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid UUID %q", s)
+	}
+
+	digits := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36]
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return UUID{}, fmt.Errorf("invalid UUID %q", s)
+	}
+
+	return u, nil
+}
+
+// String returns the UUID in its canonical lowercase form
+func (u UUID) String() string {
+	b := make([]byte, 36)
+
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+
+	return string(b)
+}
+
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+func (u *UUID) UnmarshalText(b []byte) error {
+	parsed, err := ParseUUID(string(b))
+	if err != nil {
+		return err
+	}
+
+	*u = parsed
+
+	return nil
+}
+
+// Param returns the value of the route param name, typed according to its
+// declared param type, or nil when the route has no such param
+func (c *Context) Param(name string) interface{} {
+	v, _ := c.Route.param(name)
+
+	return v
+}
+
+// ParamInt returns the route param name as an int.  A value that is not an int
+// returns an error wrapping ErrBadRequest
+func (c *Context) ParamInt(name string) (int, error) {
+	v, err := c.param(name)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int64:
+		if int64(int(n)) == n {
+			return int(n), nil
+		}
+	case string:
+		if i, err := strconv.Atoi(n); err == nil {
+			return i, nil
+		}
+	}
+
+	return 0, invalidParam(name, v, "int")
+}
+
+// ParamInt64 returns the route param name as an int64.  A value that is not an
+// int64 returns an error wrapping ErrBadRequest
+func (c *Context) ParamInt64(name string) (int64, error) {
+	v, err := c.param(name)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n := v.(type) {
+	case int64:
+		return n, nil
+	case int:
+		return int64(n), nil
+	case string:
+		if i, err := strconv.ParseInt(n, 10, 64); err == nil {
+			return i, nil
+		}
+	}
+
+	return 0, invalidParam(name, v, "int64")
+}
+
+// ParamUUID returns the route param name as a UUID.  A value that is not a UUID
+// returns an error wrapping ErrBadRequest
+func (c *Context) ParamUUID(name string) (UUID, error) {
+	v, err := c.param(name)
+	if err != nil {
+		return UUID{}, err
+	}
+
+	switch u := v.(type) {
+	case UUID:
+		return u, nil
+	case string:
+		if parsed, err := ParseUUID(u); err == nil {
+			return parsed, nil
+		}
+	}
+
+	return UUID{}, invalidParam(name, v, "UUID")
+}
+
+func (c *Context) param(name string) (interface{}, error) {
+	v, ok := c.Route.param(name)
+	if !ok {
+		return nil, fmt.Errorf("doze: route %q has no param %q", c.Route.Path(), name)
+	}
+
+	return v, nil
+}
+
+func invalidParam(name string, v interface{}, typeName string) error {
+	return fmt.Errorf("%w: param %q is not a valid %v: %q", ErrBadRequest, name, typeName, fmt.Sprint(v))
+}
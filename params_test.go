@@ -0,0 +1,208 @@
+package doze
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteParamsTyped(t *testing.T) {
+	RegisterParamType("sku", `SKU-[0-9]+`, func(s string) (interface{}, error) {
+		return strings.TrimPrefix(s, "SKU-"), nil
+	})
+
+	router := Router("TestRouteParamsTyped")
+	router.Add(NewRoute().Named("typed").
+		For("/{code}/{id:i}/{big:int64}/{uid:uuid}/{slug:slug}/{day:date}/{hash:hex}/{sku:sku}/{name:a}").
+		With(http.MethodGet, TestController{}.SimpleGet))
+
+	route, matched := router.Match("/007/10/-9000000000/123E4567-E89B-12D3-A456-426614174000/hello-world/2024-03-01/c0ffee/SKU-42/job")
+
+	assert.True(t, matched, "route should be matched")
+
+	uid, _ := ParseUUID("123e4567-e89b-12d3-a456-426614174000")
+
+	assert.Equal(t, map[string]interface{}{
+		"code": "007",
+		"id":   10,
+		"big":  int64(-9000000000),
+		"uid":  uid,
+		"slug": "hello-world",
+		"day":  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		"hash": "c0ffee",
+		"sku":  "42",
+		"name": "job",
+	}, route.Params(), "they should be equal")
+
+	path, err := route.Build(map[string]interface{}{
+		"code": "007",
+		"id":   10,
+		"big":  int64(-9000000000),
+		"uid":  uid,
+		"slug": "hello-world",
+		"day":  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		"hash": "c0ffee",
+		"sku":  "SKU-42",
+		"name": "job",
+	})
+
+	assert.Nil(t, err, "should be nil")
+	assert.Equal(t, "/007/10/-9000000000/123e4567-e89b-12d3-a456-426614174000/hello-world/2024-03-01/c0ffee/SKU-42/job", path, "they should be equal")
+}
+
+func TestRouteParamsMustParse(t *testing.T) {
+	router := Router("TestRouteParamsMustParse")
+	router.Add(NewRoute().Named("day").For("/days/{day:date}").With(http.MethodGet, TestController{}.SimpleGet))
+	router.Add(NewRoute().Named("user").For("/users/{id:i}").With(http.MethodGet, TestController{}.SimpleGet))
+	router.Add(NewRoute().Named("userName").For("/users/{name}").With(http.MethodGet, TestController{}.SimpleGet))
+	router.Add(NewRoute().Named("thing").For("/things/{id:uuid}").With(http.MethodGet, TestController{}.SimpleGet))
+	router.Add(NewRoute().Named("post").For("/posts/{slug:slug}").With(http.MethodGet, TestController{}.SimpleGet))
+
+	tests := []struct {
+		path, name string
+		matched    bool
+	}{
+		{"/days/2024-02-29", "day", true},
+		{"/days/2023-02-29", "", false},
+		{"/days/2024-13-01", "", false},
+		{"/users/99999999999999999999999", "userName", true},
+		{"/things/123e4567-e89b-12d3-a456-42661417400", "", false},
+		{"/things/123e4567xe89b-12d3-a456-426614174000", "", false},
+		{"/posts/hello--world", "", false},
+		{"/posts/Hello", "", false},
+	}
+
+	for _, test := range tests {
+		route, matched := router.Match(test.path)
+
+		assert.Equal(t, test.matched, matched, fmt.Sprintf("they should be equal for %v", test.path))
+
+		if matched {
+			assert.Equal(t, test.name, route.Name(), fmt.Sprintf("they should be equal for %v", test.path))
+		}
+	}
+}
+
+func TestContextParamAccessors(t *testing.T) {
+	var (
+		id, code, big int
+		big64         int64
+		uid           UUID
+		errs          []error
+	)
+
+	router := Router("TestContextParamAccessors")
+	router.Add(NewRoute().For("/orgs/{id:i}/{code}/{big:int64}/{uid:uuid}/{name}").With(http.MethodGet, func(c *Context) ResponseSender {
+		var err error
+
+		id, _ = c.ParamInt("id")
+		code, _ = c.ParamInt("code")
+		big64, _ = c.ParamInt64("big")
+		uid, _ = c.ParamUUID("uid")
+
+		big, err = c.ParamInt("big")
+		errs = append(errs, err)
+
+		_, err = c.ParamInt("name")
+		errs = append(errs, err)
+
+		_, err = c.ParamUUID("name")
+		errs = append(errs, err)
+
+		_, err = c.ParamInt("missing")
+		errs = append(errs, err)
+
+		assert.Equal(t, "007", c.Param("code"), "they should be equal")
+		assert.Nil(t, c.Param("missing"), "should be nil")
+
+		return NewNoContentResponse()
+	}))
+
+	NewHandler(router).ServeHTTP(httptest.NewRecorder(),
+		httptest.NewRequest(http.MethodGet, "/orgs/10/007/9000000000/123e4567-e89b-12d3-a456-426614174000/acme", nil))
+
+	assert.Equal(t, 10, id, "they should be equal")
+	assert.Equal(t, 7, code, "untyped params can still be read as an int")
+	assert.Equal(t, int64(9000000000), big64, "they should be equal")
+	assert.Equal(t, "123e4567-e89b-12d3-a456-426614174000", uid.String(), "they should be equal")
+	assert.Equal(t, 9000000000, big, "they should be equal")
+
+	assert.Nil(t, errs[0], "should be nil")
+	assert.True(t, errors.Is(errs[1], ErrBadRequest), "should be a bad request")
+	assert.Equal(t, `Bad Request: param "name" is not a valid int: "acme"`, errs[1].Error(), "they should be equal")
+	assert.True(t, errors.Is(errs[2], ErrBadRequest), "should be a bad request")
+	assert.NotNil(t, errs[3], "should not be nil")
+	assert.False(t, errors.Is(errs[3], ErrBadRequest), "should not be a bad request")
+}
+
+func TestUUIDText(t *testing.T) {
+	var u UUID
+
+	assert.Nil(t, u.UnmarshalText([]byte("00112233-4455-6677-8899-AABBCCDDEEFF")), "should be nil")
+	assert.Equal(t, UUID{0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}, u, "they should be equal")
+
+	b, _ := u.MarshalText()
+
+	assert.Equal(t, "00112233-4455-6677-8899-aabbccddeeff", string(b), "they should be equal")
+	assert.NotNil(t, u.UnmarshalText([]byte("00112233445566778899aabbccddeeff")), "should not be nil")
+}
+
+func TestRegisterParamTypeInvalid(t *testing.T) {
+	assert.Panics(t, func() { RegisterParamType("no-dashes", `.+`, nil) }, "should panic")
+	assert.Panics(t, func() { RegisterParamType("broken", `(`, nil) }, "should panic")
+}
+
+func TestRegisterParamTypeAgainKeepsExistingRoutes(t *testing.T) {
+	RegisterParamType("version", `v[0-9]+`, func(s string) (interface{}, error) { return strconv.Atoi(s[1:]) })
+
+	router := Router("TestRegisterParamTypeAgainKeepsExistingRoutes")
+	router.Add(NewRoute().For("/old/{v:version}").With(http.MethodGet, TestController{}.SimpleGet))
+
+	RegisterParamType("version", `[0-9]+\.[0-9]+`, func(s string) (interface{}, error) { return "release " + s, nil })
+
+	router.Add(NewRoute().For("/new/{v:version}").With(http.MethodGet, TestController{}.SimpleGet))
+
+	route, matched := router.Match("/old/v2")
+
+	assert.True(t, matched, "route should be matched")
+	assert.Equal(t, 2, route.Params()["v"], "existing routes should parse with the old type")
+
+	_, matched = router.Match("/old/1.2")
+
+	assert.False(t, matched, "existing routes should match with the old type")
+
+	route, matched = router.Match("/new/1.2")
+
+	assert.True(t, matched, "route should be matched")
+	assert.Equal(t, "release 1.2", route.Params()["v"], "new routes should use the new type")
+}
+
+func TestRouteParamsParsedOnceMatched(t *testing.T) {
+	var parsed []string
+
+	RegisterParamType("counted", `[0-9]+`, func(s string) (interface{}, error) {
+		parsed = append(parsed, s)
+		return strconv.Atoi(s)
+	})
+
+	router := Router("TestRouteParamsParsedOnceMatched")
+	router.Add(NewRoute().For("/n/{n:counted}{unit:a}").With(http.MethodGet, TestController{}.SimpleGet))
+
+	_, matched := router.Match("/n/12345")
+
+	assert.False(t, matched, "route should not be matched")
+	assert.Empty(t, parsed, "values should not be parsed unless the whole path matches")
+
+	route, matched := router.Match("/n/123kb")
+
+	assert.True(t, matched, "route should be matched")
+	assert.Equal(t, []string{"123"}, parsed, "they should be equal")
+	assert.Equal(t, 123, route.Params()["n"], "they should be equal")
+}
@@ -0,0 +1,144 @@
+package doze
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ProblemContentType is the media type of an RFC 7807 problem details body
+const ProblemContentType = "application/problem+json"
+
+// ProblemResponse is an RFC 7807 problem details response.  Extensions are added
+// to the top level of the JSON object next to the standard members, which always
+// take priority over an extension with the same name
+type ProblemResponse struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+// NewProblemResponse returns a ProblemResponse for the status code with the
+// default "about:blank" type, titled with the status text
+func NewProblemResponse(status int, detail string) ProblemResponse {
+	return ProblemResponse{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// NewNotFoundProblemResponse returns a ProblemResponse defaulted for not found
+func NewNotFoundProblemResponse(detail string) ProblemResponse {
+	return NewProblemResponse(http.StatusNotFound, detail)
+}
+
+// NewInternalServerErrorProblemResponse returns a ProblemResponse defaulted for an internal server error
+func NewInternalServerErrorProblemResponse(detail string) ProblemResponse {
+	return NewProblemResponse(http.StatusInternalServerError, detail)
+}
+
+// With returns a copy of the ProblemResponse with an extension member added
+func (pr ProblemResponse) With(key string, value interface{}) ProblemResponse {
+	extensions := make(map[string]interface{}, len(pr.Extensions)+1)
+	for k, v := range pr.Extensions {
+		extensions[k] = v
+	}
+	extensions[key] = value
+
+	pr.Extensions = extensions
+
+	return pr
+}
+
+// MarshalJSON flattens the standard members and extensions into one object,
+// leaving out any standard member that is empty
+func (pr ProblemResponse) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(pr.Extensions)+5)
+	for k, v := range pr.Extensions {
+		m[k] = v
+	}
+
+	members := map[string]string{
+		"type":     pr.Type,
+		"title":    pr.Title,
+		"detail":   pr.Detail,
+		"instance": pr.Instance,
+	}
+	for k, v := range members {
+		if v != "" {
+			m[k] = v
+		} else {
+			delete(m, k)
+		}
+	}
+
+	if pr.Status != 0 {
+		m["status"] = pr.Status
+	} else {
+		delete(m, "status")
+	}
+
+	return json.Marshal(m)
+}
+
+// Send writes the ProblemResponse as application/problem+json
+func (pr ProblemResponse) Send(w io.Writer) (int, error) {
+	body, err := json.Marshal(pr)
+	if err != nil {
+		return 0, err
+	}
+
+	status := pr.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	br := BasicResponse{
+		StatusCode: status,
+		Headers:    map[string]string{"Content-Type": ProblemContentType},
+		Body:       body,
+	}
+
+	return br.Send(w)
+}
+
+// ProblemErrorHandler is an ErrorHandlerFunc that answers errors with problem
+// details instead of plain text.  Errors that implement StatusCoder get their
+// status code and message as the detail, and the fields of a *ValidationError
+// or *BindError are listed in an "errors" extension.  Any other error is logged
+// and answered with a bare 500 Internal Server Error problem
+func ProblemErrorHandler(c *Context, err error) ResponseSender {
+	var sc StatusCoder
+	if !errors.As(err, &sc) || sc.StatusCode() >= http.StatusInternalServerError {
+		c.handler.logf("doze: %v %v: %v", c.Request.Method, c.Request.URL.Path, err)
+
+		status := http.StatusInternalServerError
+		if sc != nil {
+			status = sc.StatusCode()
+		}
+
+		return NewProblemResponse(status, "")
+	}
+
+	pr := NewProblemResponse(sc.StatusCode(), err.Error())
+
+	var (
+		ve *ValidationError
+		be *BindError
+	)
+
+	switch {
+	case errors.As(err, &ve):
+		pr = pr.With("errors", ve.Fields)
+	case errors.As(err, &be):
+		pr = pr.With("errors", be.Fields)
+	}
+
+	return pr
+}
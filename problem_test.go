@@ -0,0 +1,103 @@
+package doze
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProblemResponse(t *testing.T) {
+	router := Router("TestProblemResponse")
+	router.Add(NewRoute().For("/users/{id:i}").With(http.MethodGet, func(c *Context) ResponseSender {
+		return NewNotFoundProblemResponse("no user with that id").
+			With("userId", c.Route.Params()["id"]).
+			With("status", 200)
+	}))
+
+	h := NewHandler(router)
+	h.NotFound = func(c *Context) ResponseSender {
+		return ProblemResponse{Type: "https://example.com/probs/route", Title: "No such route", Status: http.StatusNotFound, Instance: c.Request.URL.Path}
+	}
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/users/12", nil))
+
+	assert.Equal(t, http.StatusNotFound, resp.Code, "they should be equal")
+	assert.Equal(t, "application/problem+json", resp.Header().Get("Content-Type"), "they should be equal")
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"no user with that id","userId":12}`, resp.Body.String(), "they should be equal")
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/nothing", nil))
+
+	assert.Equal(t, http.StatusNotFound, resp.Code, "they should be equal")
+	assert.JSONEq(t, `{"type":"https://example.com/probs/route","title":"No such route","status":404,"instance":"/nothing"}`, resp.Body.String(), "they should be equal")
+}
+
+func TestInternalServerErrorProblemResponse(t *testing.T) {
+	resp := httptest.NewRecorder()
+
+	NewInternalServerErrorProblemResponse("").Send(NewResponseWriter(resp))
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code, "they should be equal")
+	assert.JSONEq(t, `{"type":"about:blank","title":"Internal Server Error","status":500}`, resp.Body.String(), "they should be equal")
+}
+
+func TestProblemErrorHandler(t *testing.T) {
+	type CreateUser struct {
+		Name  string `json:"name" validate:"required"`
+		Email string `json:"email" validate:"required,email"`
+	}
+
+	router := Router("TestProblemErrorHandler")
+	router.Add(NewRoute().For("/users").With(http.MethodPost, ErrorAction(func(c *Context) (ResponseSender, error) {
+		var req CreateUser
+		if err := c.Bind(&req); err != nil {
+			return nil, err
+		}
+
+		if err := Validate(req); err != nil {
+			return nil, err
+		}
+
+		return NewNoContentResponse(), nil
+	})).And(http.MethodDelete, ErrorAction(func(c *Context) (ResponseSender, error) {
+		return nil, errors.New("db password wrong")
+	})))
+
+	h := NewHandler(router)
+	h.ErrorLog = log.New(io.Discard, "", 0)
+	h.ErrorHandler = ProblemErrorHandler
+
+	tests := []struct {
+		method, body string
+		code         int
+		problem      string
+	}{
+		{http.MethodPost, `{"email": "mary"}`, http.StatusUnprocessableEntity,
+			`{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"validation failed: name: is required; email: must be a valid email address",
+			"errors":[{"field":"name","message":"is required"},{"field":"email","message":"must be a valid email address"}]}`},
+		{http.MethodPost, `{"name": 5}`, http.StatusBadRequest,
+			`{"type":"about:blank","title":"Bad Request","status":400,"detail":"binding failed: name: expected string, got number",
+			"errors":[{"field":"name","message":"expected string, got number"}]}`},
+		{http.MethodDelete, "", http.StatusInternalServerError,
+			`{"type":"about:blank","title":"Internal Server Error","status":500}`},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/users", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		assert.Equal(t, test.code, resp.Code, "they should be equal")
+		assert.Equal(t, ProblemContentType, resp.Header().Get("Content-Type"), "they should be equal")
+		assert.JSONEq(t, test.problem, resp.Body.String(), "they should be equal")
+	}
+}
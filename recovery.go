@@ -0,0 +1,61 @@
+package doze
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// Panic describes a panic recovered by the Recovery middleware
+type Panic struct {
+	Value interface{}
+	Stack []byte
+	Route string
+}
+
+func (p *Panic) Error() string {
+	return fmt.Sprintf("panic in route %q: %v", p.Route, p.Value)
+}
+
+// PanicHandlerFunc builds the response for a recovered panic
+type PanicHandlerFunc func(*Context, *Panic) ResponseSender
+
+// Recovery returns a MiddlewareFunc that recovers panics from the rest of the
+// chain.  The panic is logged with its stack and the name of the matched route,
+// then the response from onPanic is sent, or a plain 500 Internal Server Error
+// when onPanic is nil.  Nothing is sent if the response has already been
+// written to, as that would corrupt it.
+//
+// http.ErrAbortHandler is not recovered so net/http can abort the connection
+func Recovery(onPanic PanicHandlerFunc) MiddlewareFunc {
+	return func(c *Context, next NextFunc) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			p := &Panic{Value: v, Stack: debug.Stack(), Route: c.Route.Name()}
+
+			c.handler.logf("doze: %v %v: %v\n%s", c.Request.Method, c.Request.URL.Path, p, p.Stack)
+
+			if c.ResponseWriter.Written() {
+				return
+			}
+
+			doAction(c, func(c *Context) ResponseSender {
+				if onPanic != nil {
+					return onPanic(c, p)
+				}
+
+				return NewInternalServerErrorResponse()
+			})
+		}()
+
+		next(c)
+	}
+}
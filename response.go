@@ -20,6 +20,9 @@ type BasicResponse struct {
 }
 
 // GzipResponse is a wrapping response to gzip your BasicResponse
+//
+// Deprecated: GzipResponse compresses whether or not the client accepts gzip.
+// Use the Compress middleware instead, which works with any ResponseSender
 type GzipResponse struct {
 	BasicResponse
 }
@@ -53,6 +56,14 @@ func NewNotFoundResponse() BasicResponse {
 	}
 }
 
+// NewMethodNotAllowedResponse returns a BasicResponse defaulted for method not allowed
+func NewMethodNotAllowedResponse() BasicResponse {
+	return BasicResponse{
+		StatusCode: http.StatusMethodNotAllowed,
+		Body:       []byte(http.StatusText(http.StatusMethodNotAllowed)),
+	}
+}
+
 // NewInternalServerErrorResponse returns a BasicResponse defaulted for an internal server error
 func NewInternalServerErrorResponse() BasicResponse {
 	return BasicResponse{
@@ -61,7 +72,23 @@ func NewInternalServerErrorResponse() BasicResponse {
 	}
 }
 
-func (br BasicResponse) setHeaders(w *ResponseWriter) {
+type errorResponse struct {
+	err error
+}
+
+// NewErrorResponse returns a ResponseSender that hands the error to the Handler's
+// ErrorHandler, which decides what is actually sent
+func NewErrorResponse(err error) ResponseSender {
+	return errorResponse{err}
+}
+
+// Send is only used when the errorResponse is sent outside of a Handler and
+// writes an internal server error
+func (er errorResponse) Send(w io.Writer) (int, error) {
+	return NewInternalServerErrorResponse().Send(w)
+}
+
+func (br BasicResponse) setHeaders(w ResponseWriter) {
 	for k, v := range br.Headers {
 		w.Header().Set(k, v)
 	}
@@ -70,10 +97,15 @@ func (br BasicResponse) setHeaders(w *ResponseWriter) {
 
 // Send writes the BasicResponse body to the http.ResponseWriter
 func (br BasicResponse) Send(w io.Writer) (int, error) {
-	if rw, ok := w.(*ResponseWriter); ok {
+	if rw, ok := w.(ResponseWriter); ok {
 		br.setHeaders(rw)
 	}
 
+	// statuses such as 204 and 304 do not allow a body to be written at all
+	if len(br.Body) == 0 {
+		return 0, nil
+	}
+
 	return w.Write(br.Body)
 }
 
@@ -91,15 +123,26 @@ func basicJSONResponse(body interface{}) BasicResponse {
 }
 
 // NewGzipResponse creates a new GzipResponse that wraps a BasicResponse that adds Content-Encoding to gzip
+//
+// Deprecated: use the Compress middleware instead
 func NewGzipResponse(br BasicResponse) GzipResponse {
-	br.Headers["Content-Encoding"] = "gzip"
+	headers := make(map[string]string, len(br.Headers)+1)
+	for k, v := range br.Headers {
+		headers[k] = v
+	}
+	headers["Content-Encoding"] = "gzip"
+
+	br.Headers = headers
 
 	return GzipResponse{br}
 }
 
 // Send creates a gzip writer and writes to the http.ResponseWriter
 func (gr GzipResponse) Send(w io.Writer) (int, error) {
-	gr.BasicResponse.setHeaders(w.(*ResponseWriter))
+	if rw, ok := w.(ResponseWriter); ok {
+		rw.Header().Del("Content-Length")
+		gr.BasicResponse.setHeaders(rw)
+	}
 
 	gz := gzip.NewWriter(w)
 	defer gz.Close()
@@ -5,13 +5,17 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type DozeRoute struct {
 	name        string
 	path        string
 	actions     map[string]ActionFunc
+	middleware  []MiddlewareFunc
+	methodMw    map[string][]MiddlewareFunc
 	paramNames  []string
+	paramTypes  []*paramType
 	paramValues []interface{}
 }
 
@@ -41,6 +45,18 @@ func (r *DozeRoute) Actions() map[string]ActionFunc {
 	return r.actions
 }
 
+// Middleware returns the MiddlewareFuncs that run only for this route, after the
+// Handler's global middleware
+func (r *DozeRoute) Middleware() []MiddlewareFunc {
+	return r.middleware
+}
+
+// MethodMiddleware returns the MiddlewareFuncs that run only for a single method
+// of this route, after the route middleware
+func (r *DozeRoute) MethodMiddleware() map[string][]MiddlewareFunc {
+	return r.methodMw
+}
+
 func (r *DozeRoute) ParamNames() []string {
 	return r.paramNames
 }
@@ -61,34 +77,126 @@ func (r *DozeRoute) SetActions(actions map[string]ActionFunc) {
 	r.actions = actions
 }
 
+func (r *DozeRoute) SetMiddleware(middleware []MiddlewareFunc) {
+	r.middleware = middleware
+}
+
+func (r *DozeRoute) SetMethodMiddleware(methodMw map[string][]MiddlewareFunc) {
+	r.methodMw = methodMw
+}
+
 func (r *DozeRoute) SetParamNames(paramNames []string) {
 	r.paramNames = paramNames
 }
 
+// SetParamValues stores param values on the route itself.  Routers must not call
+// it while matching as the route is shared between requests, use
+// NewPatternedRoute instead
 func (r *DozeRoute) SetParamValues(paramValues []interface{}) {
 	r.paramValues = paramValues
 }
 
+func (r *DozeRoute) resolvedParamTypes() []*paramType {
+	return r.paramTypes
+}
+
+func (r *DozeRoute) setResolvedParamTypes(paramTypes []*paramType) {
+	r.paramTypes = paramTypes
+}
+
+// routeMiddleware is implemented by routes that carry their own middleware, such
+// as DozeRoute.  It is not part of Route so that routes written before route and
+// method middleware existed keep working, they just cannot have any
+type routeMiddleware interface {
+	Middleware() []MiddlewareFunc
+	MethodMiddleware() map[string][]MiddlewareFunc
+	SetMiddleware([]MiddlewareFunc)
+	SetMethodMiddleware(map[string][]MiddlewareFunc)
+}
+
+// paramTyped is implemented by routes that keep the param types resolved when
+// they were added to a router, so a type registered again later does not change
+// how their params are parsed
+type paramTyped interface {
+	resolvedParamTypes() []*paramType
+	setResolvedParamTypes([]*paramType)
+}
+
+// PatternedRoute is the result of matching a request path against a Route.  The
+// embedded Route is shared by every request and must be treated as read-only,
+// the matched param values belong to the request alone
 type PatternedRoute struct {
 	Route
+	values []interface{}
+}
+
+// NewPatternedRoute returns a PatternedRoute for the route with the param values
+// matched from a request path.  It is meant for custom Routeable implementations
+// and is the concurrency safe way to return params, as calling SetParamValues on
+// a shared route lets concurrent requests see each other's values
+func NewPatternedRoute(route Route, values []interface{}) PatternedRoute {
+	return PatternedRoute{route, values}
+}
+
+// ParamValues returns the param values matched for this request.  When the
+// PatternedRoute was not created with NewPatternedRoute the values set on the
+// route with SetParamValues are returned instead
+func (r PatternedRoute) ParamValues() []interface{} {
+	if r.values == nil && r.Route != nil {
+		return r.Route.ParamValues()
+	}
+
+	return r.values
 }
 
 // Params returns a key-value pair containing the route parameters defined in the
-// route path.  ParamNames should alwyas go 1-1 to the ParamValues, otherwise you
-// will have a bad time
+// route path.  Values are converted by the parser of their declared param type,
+// untyped params and types without a parser stay strings.  ParamNames should
+// alwyas go 1-1 to the ParamValues, otherwise you will have a bad time
 func (r PatternedRoute) Params() map[string]interface{} {
 	pv := make(map[string]interface{})
 
 	paramNames := r.ParamNames()
+	types := r.paramTypes()
+
 	for i, v := range r.ParamValues() {
-		pv[paramNames[i]] = v
-		if n, err := strconv.Atoi(v.(string)); err == nil {
-			pv[paramNames[i]] = n
-		}
+		pv[paramNames[i]] = paramValue(types, i, v)
 	}
 	return pv
 }
 
+// param returns the value of a single param the same way Params does
+func (r PatternedRoute) param(name string) (interface{}, bool) {
+	values := r.ParamValues()
+
+	for i, n := range r.ParamNames() {
+		if n == name && i < len(values) {
+			return paramValue(r.paramTypes(), i, values[i]), true
+		}
+	}
+
+	return nil, false
+}
+
+// paramTypes returns the param types resolved when the route was added.  Routes
+// that were not added to a RestRouter have their types looked up now
+func (r PatternedRoute) paramTypes() []*paramType {
+	if pt, ok := r.Route.(paramTyped); ok && pt.resolvedParamTypes() != nil {
+		return pt.resolvedParamTypes()
+	}
+
+	return resolveParamTypes(r.Path())
+}
+
+func paramValue(types []*paramType, i int, v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok || i >= len(types) {
+		return v
+	}
+
+	return types[i].value(s)
+}
+
 // Build returns the route path with route parameters replaced with values from the
 // passed in map
 func (r PatternedRoute) Build(m map[string]interface{}) (string, error) {
@@ -102,7 +210,7 @@ func (r PatternedRoute) Build(m map[string]interface{}) (string, error) {
 	regstrs := make([]string, len(m))
 
 	for p := range m {
-		regstrs[i] = fmt.Sprintf(`{(%v)(?::\w+)?}`, p)
+		regstrs[i] = fmt.Sprintf(`{(%v)(?::\w+|:\*)?}`, p)
 
 		i++
 	}
@@ -133,6 +241,12 @@ func (r PatternedRoute) Build(m map[string]interface{}) (string, error) {
 			return strconv.FormatFloat(value.(float64), 'f', -1, 64)
 		case string:
 			return value.(string)
+		case int64:
+			return strconv.FormatInt(value.(int64), 10)
+		case time.Time:
+			return value.(time.Time).Format(dateLayout)
+		case fmt.Stringer:
+			return value.(fmt.Stringer).String()
 		}
 
 		return ""
@@ -1,6 +1,7 @@
 package doze
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 	"sync"
@@ -10,19 +11,31 @@ const (
 	intParam      = "i"
 	alphaParam    = "a"
 	alphaNumParam = "an"
+	catchAllParam = "*"
 )
 
-var regParam = regexp.MustCompile(`{(\w+)(:\w+)?}`)
-var regMap = map[string]string{
-	intParam:      `([0-9]+)`,
-	alphaParam:    `([A-Za-z]+)`,
-	alphaNumParam: `([0-9A-Za-z]+)`,
-}
-
+var regParam = regexp.MustCompile(`{(\w+)(:\w+|:\*)?}`)
+
+// RestRouter is the default Routeable.  Routes are kept in a radix tree keyed by
+// their path so matching only depends on the length of the request path and not
+// on the number of routes registered.
+//
+// Route paths are made of static text and params:
+//
+//	{name}     matches any text up to the next "/"
+//	{name:i}   a typed param, matches only when the text fits the type, see
+//	           RegisterParamType for the built in types
+//	{name:*}   a catch-all, matches the rest of the path including any "/"
+//
+// When several routes could match the same path, precedence is decided segment by
+// segment from left to right: static text beats typed params, typed params beat
+// untyped params, and untyped params beat catch-alls.  Params of the same kind
+// are tried in the order their routes were added
 type RestRouter struct {
 	prefix     string
 	routes     map[string]Route
-	routingMap map[Route]*regexp.Regexp
+	tree       *node
+	middleware []MiddlewareFunc
 }
 
 var (
@@ -41,7 +54,7 @@ func Router(name string) RestRouter {
 
 	// create new router if it doesn't exist
 	if _, ok := routers[name]; !ok {
-		routers[name] = RestRouter{"", make(map[string]Route), make(map[Route]*regexp.Regexp)}
+		routers[name] = RestRouter{"", make(map[string]Route), newTree(), nil}
 	}
 
 	return routers[name]
@@ -57,9 +70,25 @@ func (ro RestRouter) Prefix() string {
 	return ro.prefix
 }
 
+// Group returns a sub-router that shares the routes of this router.  Routes added
+// through the group have the group prefix appended to the current prefix and run
+// the group middleware after any middleware of the enclosing groups.  Groups can
+// be nested.  Adding a Route without Middleware and SetMiddleware methods to a
+// group with middleware panics, as the middleware could not run for it
+func (ro RestRouter) Group(prefix string, mw ...MiddlewareFunc) RestRouter {
+	middleware := make([]MiddlewareFunc, 0, len(ro.middleware)+len(mw))
+	middleware = append(middleware, ro.middleware...)
+	middleware = append(middleware, mw...)
+
+	ro.prefix = ro.prefix + prefix
+	ro.middleware = middleware
+
+	return ro
+}
+
 // NewRoute returns a new *DozeRoute
 func NewRoute() *DozeRoute {
-	return &DozeRoute{actions: make(map[string]ActionFunc)}
+	return &DozeRoute{actions: make(map[string]ActionFunc), methodMw: make(map[string][]MiddlewareFunc)}
 }
 
 func (r *DozeRoute) Named(name string) *DozeRoute {
@@ -74,21 +103,48 @@ func (r *DozeRoute) For(path string) *DozeRoute {
 	return r
 }
 
-func (r *DozeRoute) With(method string, action ActionFunc) *DozeRoute {
+// With sets the action for a method.  Any MiddlewareFunc given runs only for
+// that method, after the route middleware
+func (r *DozeRoute) With(method string, action ActionFunc, mw ...MiddlewareFunc) *DozeRoute {
 	actions := r.Actions()
 	actions[method] = action
 	r.SetActions(actions)
 
+	methodMw := r.MethodMiddleware()
+	methodMw[method] = mw
+	r.SetMethodMiddleware(methodMw)
+
 	return r
 }
 
-func (r *DozeRoute) And(method string, action ActionFunc) *DozeRoute {
-	return r.With(method, action)
+func (r *DozeRoute) And(method string, action ActionFunc, mw ...MiddlewareFunc) *DozeRoute {
+	return r.With(method, action, mw...)
+}
+
+// Use applies a MiddlewareFunc to every method of the route.  Route middleware
+// runs after the Handler's global middleware and before any method middleware
+func (r *DozeRoute) Use(mw ...MiddlewareFunc) *DozeRoute {
+	r.SetMiddleware(append(r.Middleware(), mw...))
+
+	return r
 }
 
 func (ro RestRouter) Add(route Route) {
 	route.SetPath(ro.Prefix() + route.Path())
 
+	if len(ro.middleware) > 0 {
+		rm, ok := route.(routeMiddleware)
+		if !ok {
+			panic(fmt.Sprintf("doze: route %q cannot have group middleware", route.Path()))
+		}
+
+		middleware := make([]MiddlewareFunc, 0, len(ro.middleware)+len(rm.Middleware()))
+		middleware = append(middleware, ro.middleware...)
+		middleware = append(middleware, rm.Middleware()...)
+
+		rm.SetMiddleware(middleware)
+	}
+
 	initRoute(ro, route)
 
 	if route.Name() != "" {
@@ -101,49 +157,41 @@ func (ro RestRouter) Add(route Route) {
 func initRoute(router RestRouter, route Route) {
 	toSub := regParam.FindAllStringSubmatch(route.Path(), -1)
 
-	regString := route.Path()
-
 	if len(toSub) > 0 {
 		params := make([]string, len(toSub))
 
 		for i, v := range toSub {
-			whole, param, pType, regex := v[0], v[1], v[2], `([^/]+)`
-
-			params[i] = param
-
-			if len(pType) > 1 {
-				if r, ok := regMap[pType[1:]]; ok {
-					regex = r
-				}
-			}
-			regString = strings.Replace(regString, whole, regex, -1)
+			params[i] = v[1]
 		}
 
 		route.SetParamNames(params)
 	}
 
-	router.routingMap[route] = regexp.MustCompile(regString + "/?")
+	if pt, ok := route.(paramTyped); ok {
+		pt.setResolvedParamTypes(resolveParamTypes(route.Path()))
+	}
+
+	router.tree.insert(route.Path(), route)
 }
 
 func (ro RestRouter) Get(name string) PatternedRoute {
-	return PatternedRoute{ro.routes[name]}
+	return PatternedRoute{Route: ro.routes[name]}
 }
 
+// Match returns the route registered for the path.  A single trailing slash on
+// the path is ignored
 func (ro RestRouter) Match(test string) (PatternedRoute, bool) {
-	for route, regex := range ro.routingMap {
-		matches := regex.FindStringSubmatch(test)
-		if matches != nil && matches[0] == test {
-			values := make([]interface{}, len(matches[1:]))
+	values := make([]interface{}, 0, 4)
 
-			for i, m := range matches[1:] {
-				values[i] = m
-			}
+	route, values, ok := ro.tree.lookup(test, values)
 
-			route.SetParamValues(values)
+	if !ok && len(test) > 1 && strings.HasSuffix(test, "/") {
+		route, values, ok = ro.tree.lookup(test[:len(test)-1], values[:0])
+	}
 
-			return PatternedRoute{route}, true
-		}
+	if !ok {
+		return PatternedRoute{}, false
 	}
 
-	return PatternedRoute{}, false
+	return NewPatternedRoute(route, values), true
 }
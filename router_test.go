@@ -1,9 +1,13 @@
 package doze
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -77,6 +81,21 @@ func TestRouteParams(t *testing.T) {
 	assert.Equal(t, "job", route.Params()["name"], "they should match")
 }
 
+func TestPatternedRouteFallsBackToRouteParams(t *testing.T) {
+	route := NewRoute().For("/people/{id:i}")
+	route.SetParamNames([]string{"id"})
+	route.SetParamValues([]interface{}{"10"})
+
+	pr := PatternedRoute{Route: route}
+
+	assert.Equal(t, []interface{}{"10"}, pr.ParamValues(), "they should match")
+	assert.Equal(t, 10, pr.Params()["id"], "they should match")
+
+	pr = NewPatternedRoute(route, []interface{}{"12"})
+
+	assert.Equal(t, 12, pr.Params()["id"], "matched values should win")
+}
+
 func TestRouteBuildShouldError(t *testing.T) {
 	router := Router("TestRouteBuildShouldError")
 	router.Add(NewRoute().Named("test").For("/people/{id:i}/details/{name}").With("GET", TestController{}.SimpleGet))
@@ -126,6 +145,279 @@ func TestRouterPrefix(t *testing.T) {
 	assert.Equal(t, "/api/v3/people/{id:i}/details/{name:a}", testRoute.Path(), "Paths should match")
 }
 
+func TestRouterMatchStaticSharedPrefix(t *testing.T) {
+	router := Router("TestRouterMatchStaticSharedPrefix")
+	router.Add(NewRoute().Named("people").For("/people").With("GET", TestController{}.SimpleGet))
+	router.Add(NewRoute().Named("peopleSearch").For("/people/search").With("GET", TestController{}.SimpleGet))
+	router.Add(NewRoute().Named("pets").For("/pets").With("GET", TestController{}.SimpleGet))
+
+	route, matched := router.Match("/people/search")
+
+	assert.True(t, matched, "route should be matched")
+	assert.Equal(t, "peopleSearch", route.Name(), "they should match")
+
+	route, matched = router.Match("/pets")
+
+	assert.True(t, matched, "route should be matched")
+	assert.Equal(t, "pets", route.Name(), "they should match")
+
+	_, matched = router.Match("/pe")
+
+	assert.False(t, matched, "route should not be matched")
+}
+
+func TestRouterMatchTrailingSlash(t *testing.T) {
+	router := Router("TestRouterMatchTrailingSlash")
+	router.Add(NewRoute().Named("person").For("/people/{id:i}").With("GET", TestController{}.SimpleGet))
+
+	route, matched := router.Match("/people/10/")
+
+	assert.True(t, matched, "route should be matched")
+	assert.Equal(t, 10, route.Params()["id"], "they should match")
+
+	_, matched = router.Match("/people/10//")
+
+	assert.False(t, matched, "route should not be matched")
+}
+
+func TestRouterMatchParamWithinSegment(t *testing.T) {
+	router := Router("TestRouterMatchParamWithinSegment")
+	router.Add(NewRoute().For("/files/{name}.{ext:a}").With("GET", TestController{}.SimpleGet))
+
+	route, matched := router.Match("/files/report.v2.json")
+
+	assert.True(t, matched, "route should be matched")
+	assert.Equal(t, "report.v2", route.Params()["name"], "they should match")
+	assert.Equal(t, "json", route.Params()["ext"], "they should match")
+
+	_, matched = router.Match("/files/report.7")
+
+	assert.False(t, matched, "route should not be matched")
+}
+
+func TestRouterMatchSameAsRegex(t *testing.T) {
+	paths := []string{
+		"/people/{id:i}/details/{name:a}",
+		"/people/{id:i}/code/{code:an}",
+		"/people/{id:i}",
+		"/teams/{team}/members/{id:i}",
+		"/teams",
+	}
+
+	tests := []string{
+		"/people/10/details/job",
+		"/people/10/details/10",
+		"/people/10/code/ab12",
+		"/people/10/code/ab-12",
+		"/people/10",
+		"/people/job",
+		"/teams/red/members/3",
+		"/teams/red/members/x",
+		"/teams",
+		"/teams/",
+		"/nothing",
+	}
+
+	router := Router("TestRouterMatchSameAsRegex")
+	legacy := newRegexRouter()
+
+	for _, p := range paths {
+		router.Add(NewRoute().For(p).With("GET", TestController{}.SimpleGet))
+		legacy.add(p)
+	}
+
+	for _, test := range tests {
+		route, matched := router.Match(test)
+		path, params, legacyMatched := legacy.match(test)
+
+		assert.Equal(t, legacyMatched, matched, "match should be the same for "+test)
+
+		if matched {
+			assert.Equal(t, path, route.Path(), "route should be the same for "+test)
+			assert.Equal(t, params, route.ParamValues(), "params should be the same for "+test)
+		}
+	}
+}
+
+func TestRouterMatchLongSegment(t *testing.T) {
+	router := Router("TestRouterMatchLongSegment")
+	router.Add(NewRoute().For("/users/{id:i}").With("GET", TestController{}.SimpleGet))
+	router.Add(NewRoute().For("/files/{name}.{ext:a}").With("GET", TestController{}.SimpleGet))
+
+	long := strings.Repeat("1", 64000)
+
+	done := make(chan bool)
+	go func() {
+		_, usersMatched := router.Match("/users/" + long + "x")
+		_, filesMatched := router.Match("/files/" + long + ".1")
+
+		done <- usersMatched || filesMatched
+	}()
+
+	select {
+	case matched := <-done:
+		assert.False(t, matched, "route should not be matched")
+	case <-time.After(5 * time.Second):
+		t.Fatal("matching a long segment should not try every prefix")
+	}
+
+	route, matched := router.Match("/files/" + long + ".txt")
+
+	assert.True(t, matched, "route should be matched")
+	assert.Equal(t, long, route.Params()["name"], "they should match")
+}
+
+func TestRouterMatchCatchAll(t *testing.T) {
+	router := Router("TestRouterMatchCatchAll")
+	router.Add(NewRoute().Named("assets").For("/assets/{file:*}").With("GET", TestController{}.SimpleGet))
+
+	route, matched := router.Match("/assets/css/site/main.css")
+
+	assert.True(t, matched, "route should be matched")
+	assert.Equal(t, "css/site/main.css", route.Params()["file"], "they should match")
+
+	_, matched = router.Match("/assets/")
+
+	assert.False(t, matched, "route should not be matched")
+
+	s, _ := router.Get("assets").Build(map[string]interface{}{"file": "js/app.js"})
+
+	assert.Equal(t, "/assets/js/app.js", s, "they should match")
+}
+
+func TestRouterPrecedence(t *testing.T) {
+	paths := map[string]string{
+		"static":   "/people/me",
+		"typed":    "/people/{id:i}",
+		"untyped":  "/people/{id}",
+		"catchAll": "/people/{rest:*}",
+	}
+
+	orders := [][]string{
+		{"static", "typed", "untyped", "catchAll"},
+		{"catchAll", "untyped", "typed", "static"},
+		{"untyped", "catchAll", "static", "typed"},
+		{"typed", "static", "catchAll", "untyped"},
+	}
+
+	tests := map[string]string{
+		"/people/me":     "static",
+		"/people/10":     "typed",
+		"/people/joe":    "untyped",
+		"/people/10/pet": "catchAll",
+		"/people/me/pet": "catchAll",
+	}
+
+	for i, order := range orders {
+		router := Router(fmt.Sprintf("TestRouterPrecedence_%d", i))
+
+		for _, name := range order {
+			router.Add(NewRoute().Named(name).For(paths[name]).With("GET", TestController{}.SimpleGet))
+		}
+
+		for test, expected := range tests {
+			for n := 0; n < 20; n++ {
+				route, matched := router.Match(test)
+
+				assert.True(t, matched, "route should be matched for "+test)
+				assert.Equal(t, expected, route.Name(), fmt.Sprintf("wrong route for %v with order %v", test, order))
+			}
+		}
+	}
+}
+
+func TestRouterPrecedenceFallsBack(t *testing.T) {
+	router := Router("TestRouterPrecedenceFallsBack")
+	router.Add(NewRoute().Named("meDetails").For("/people/me/details").With("GET", TestController{}.SimpleGet))
+	router.Add(NewRoute().Named("personPets").For("/people/{id}/pets").With("GET", TestController{}.SimpleGet))
+	router.Add(NewRoute().Named("typedFirst").For("/teams/{id:i}/members").With("GET", TestController{}.SimpleGet))
+	router.Add(NewRoute().Named("untypedSecond").For("/teams/{name}/{section}").With("GET", TestController{}.SimpleGet))
+
+	route, matched := router.Match("/people/me/pets")
+
+	assert.True(t, matched, "route should be matched")
+	assert.Equal(t, "personPets", route.Name(), "static segment without a matching route should fall back to params")
+	assert.Equal(t, "me", route.Params()["id"], "they should match")
+
+	route, matched = router.Match("/teams/10/members")
+
+	assert.True(t, matched, "route should be matched")
+	assert.Equal(t, "typedFirst", route.Name(), "they should match")
+
+	route, matched = router.Match("/teams/10/history")
+
+	assert.True(t, matched, "route should be matched")
+	assert.Equal(t, "untypedSecond", route.Name(), "they should match")
+}
+
+func TestRouterGroup(t *testing.T) {
+	router := Router("TestRouterGroup").SetPrefix("/api")
+	admin := router.Group("/admin")
+	users := admin.Group("/users")
+
+	router.Add(NewRoute().Named("root").For("/status").With("GET", TestController{}.SimpleGet))
+	admin.Add(NewRoute().Named("admin").For("/status").With("GET", TestController{}.SimpleGet))
+	users.Add(NewRoute().Named("user").For("/{id:i}").With("GET", TestController{}.SimpleGet))
+
+	assert.Equal(t, "/api/status", router.Get("root").Path(), "they should match")
+	assert.Equal(t, "/api/admin/status", router.Get("admin").Path(), "they should match")
+	assert.Equal(t, "/api/admin/users/{id:i}", users.Get("user").Path(), "they should match")
+	assert.Equal(t, "/api/admin/users", users.Prefix(), "they should match")
+
+	route, matched := router.Match("/api/admin/users/10")
+
+	assert.True(t, matched, "route should be matched")
+	assert.Equal(t, "user", route.Name(), "they should match")
+}
+
+// customRoute implements Route and nothing else, the way routes written outside
+// of this package do
+type customRoute struct {
+	name, path  string
+	actions     map[string]ActionFunc
+	paramNames  []string
+	paramValues []interface{}
+}
+
+func (r *customRoute) Name() string                       { return r.name }
+func (r *customRoute) Path() string                       { return r.path }
+func (r *customRoute) Actions() map[string]ActionFunc     { return r.actions }
+func (r *customRoute) ParamNames() []string               { return r.paramNames }
+func (r *customRoute) ParamValues() []interface{}         { return r.paramValues }
+func (r *customRoute) SetName(name string)                { r.name = name }
+func (r *customRoute) SetPath(path string)                { r.path = path }
+func (r *customRoute) SetActions(a map[string]ActionFunc) { r.actions = a }
+func (r *customRoute) SetParamNames(names []string)       { r.paramNames = names }
+func (r *customRoute) SetParamValues(values []interface{}) {
+	r.paramValues = values
+}
+
+func TestRouterCustomRoute(t *testing.T) {
+	router := Router("TestRouterCustomRoute")
+	router.Add(&customRoute{name: "custom", path: "/custom/{id:i}", actions: map[string]ActionFunc{
+		"GET": func(c *Context) ResponseSender {
+			return NewOKJSONResponse(c.Route.Params())
+		},
+	}})
+
+	h := NewHandler(router)
+	h.Use(func(c *Context, next NextFunc) {
+		c.ResponseWriter.Header().Set("X-Global", "yes")
+		next(c)
+	})
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest("GET", "/custom/10", nil))
+
+	assert.Equal(t, http.StatusOK, resp.Code, "they should match")
+	assert.Equal(t, "yes", resp.Header().Get("X-Global"), "global middleware should run")
+	assert.Equal(t, `{"id":10}`, resp.Body.String(), "they should match")
+
+	assert.Panics(t, func() {
+		router.Group("/admin", func(c *Context, next NextFunc) {}).Add(&customRoute{path: "/users", actions: map[string]ActionFunc{}})
+	}, "group middleware cannot be added to a custom route")
+}
+
 /// BENCHMARKS
 
 func BenchmarkRouterMatch2(b *testing.B) {
@@ -157,3 +449,107 @@ func BenchmarkRouterMatch2(b *testing.B) {
 		h.ServeHTTP(resp, req)
 	}
 }
+
+// regexRouter is the regex scan RestRouter used before the routing tree, kept
+// here to compare matching behaviour and speed
+type regexRouter struct {
+	paths   []string
+	regexes []*regexp.Regexp
+}
+
+func newRegexRouter() *regexRouter {
+	return &regexRouter{}
+}
+
+func (rr *regexRouter) add(path string) {
+	regString := path
+
+	for _, v := range regParam.FindAllStringSubmatch(path, -1) {
+		whole, pType, regex := v[0], v[2], `([^/]+)`
+
+		if len(pType) > 1 {
+			if pt := lookupParamType(pType[1:]); pt != nil {
+				regex = "(" + pt.pattern + ")"
+			}
+		}
+		regString = strings.Replace(regString, whole, regex, -1)
+	}
+
+	rr.paths = append(rr.paths, path)
+	rr.regexes = append(rr.regexes, regexp.MustCompile(regString+"/?"))
+}
+
+func (rr *regexRouter) match(test string) (string, []interface{}, bool) {
+	for i, regex := range rr.regexes {
+		matches := regex.FindStringSubmatch(test)
+		if matches != nil && matches[0] == test {
+			values := make([]interface{}, len(matches[1:]))
+
+			for i, m := range matches[1:] {
+				values[i] = m
+			}
+
+			return rr.paths[i], values, true
+		}
+	}
+
+	return "", nil, false
+}
+
+var benchmarkRouteCounts = []int{10, 100, 300, 1000}
+
+func benchmarkRoutePath(i int) string {
+	return fmt.Sprintf("/resource%d/{id:i}/children/{name}", i)
+}
+
+func benchmarkRequestPath(i int) string {
+	return fmt.Sprintf("/resource%d/42/children/joe", i)
+}
+
+func BenchmarkRouterMatchTree(b *testing.B) {
+	for _, count := range benchmarkRouteCounts {
+		b.Run(fmt.Sprintf("routes=%d", count), func(b *testing.B) {
+			rr := Router(fmt.Sprintf("BenchmarkRouterMatchTree_%d", count))
+			for i := 0; i < count; i++ {
+				rr.Add(NewRoute().For(benchmarkRoutePath(i)).With("GET", TestController{}.SimpleGet))
+			}
+
+			test := benchmarkRequestPath(count - 1)
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				rr.Match(test)
+			}
+		})
+	}
+}
+
+func BenchmarkRouterMatchRegex(b *testing.B) {
+	for _, count := range benchmarkRouteCounts {
+		b.Run(fmt.Sprintf("routes=%d", count), func(b *testing.B) {
+			rr := newRegexRouter()
+			for i := 0; i < count; i++ {
+				rr.add(benchmarkRoutePath(i))
+			}
+
+			test := benchmarkRequestPath(count - 1)
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				rr.match(test)
+			}
+		})
+	}
+}
+
+func BenchmarkRouterMatchLongSegment(b *testing.B) {
+	rr := Router("BenchmarkRouterMatchLongSegment")
+	rr.Add(NewRoute().For("/users/{id:i}").With("GET", TestController{}.SimpleGet))
+
+	test := "/users/" + strings.Repeat("1", 16000) + "x"
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		rr.Match(test)
+	}
+}
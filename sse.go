@@ -0,0 +1,145 @@
+package doze
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHeartbeat is how often an EventStreamResponse sends a comment to keep
+// the connection open when its Heartbeat is 0
+const DefaultHeartbeat = 15 * time.Second
+
+// Event is a single Server-Sent Event.  Empty fields are left out of the frame
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// EventStreamResponse sends Events as text/event-stream until the channel is
+// closed or the request's context is done.  A negative Heartbeat disables
+// heartbeats
+type EventStreamResponse struct {
+	Headers   map[string]string
+	Events    <-chan Event
+	Heartbeat time.Duration
+}
+
+// NewEventStreamResponse returns an EventStreamResponse for the channel
+func NewEventStreamResponse(events <-chan Event) EventStreamResponse {
+	return EventStreamResponse{Events: events}
+}
+
+// LastEventID returns the id of the last event a reconnecting EventSource
+// received, or an empty string on the first connection
+func (c *Context) LastEventID() string {
+	return c.Request.Header.Get("Last-Event-ID")
+}
+
+// EventStream returns an EventStreamResponse for the events from source.  The
+// source is given the Last-Event-ID of the request so it can resume the stream
+// after the last event the client saw
+func (c *Context) EventStream(source func(lastEventID string) <-chan Event) EventStreamResponse {
+	return NewEventStreamResponse(source(c.LastEventID()))
+}
+
+// Send writes each Event as a frame and flushes it straight away
+func (er EventStreamResponse) Send(w io.Writer) (int, error) {
+	ctx := context.Background()
+
+	if rw, ok := w.(ResponseWriter); ok {
+		if r := rw.Request(); r != nil {
+			ctx = r.Context()
+		}
+
+		headers := map[string]string{
+			"Content-Type":      "text/event-stream",
+			"Cache-Control":     "no-cache",
+			"X-Accel-Buffering": "no",
+		}
+		for k, v := range er.Headers {
+			headers[k] = v
+		}
+
+		BasicResponse{StatusCode: http.StatusOK, Headers: headers}.setHeaders(rw)
+	}
+
+	flush(w)
+
+	var heartbeat <-chan time.Time
+
+	interval := er.Heartbeat
+	if interval == 0 {
+		interval = DefaultHeartbeat
+	}
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		heartbeat = ticker.C
+	}
+
+	var written int
+
+	for {
+		var frame string
+
+		select {
+		case <-ctx.Done():
+			return written, nil
+		case <-heartbeat:
+			frame = ": heartbeat\n\n"
+		case ev, ok := <-er.Events:
+			if !ok {
+				return written, nil
+			}
+
+			frame = ev.frame()
+		}
+
+		n, err := io.WriteString(w, frame)
+		written += n
+
+		if err != nil {
+			return written, err
+		}
+
+		flush(w)
+	}
+}
+
+func (ev Event) frame() string {
+	var sb strings.Builder
+
+	if ev.ID != "" {
+		sb.WriteString("id: " + singleLine(ev.ID) + "\n")
+	}
+
+	if ev.Event != "" {
+		sb.WriteString("event: " + singleLine(ev.Event) + "\n")
+	}
+
+	if ev.Retry > 0 {
+		sb.WriteString("retry: " + strconv.FormatInt(int64(ev.Retry/time.Millisecond), 10) + "\n")
+	}
+
+	// EventSource ends a line at \r\n, \r or \n, so each becomes its own data line
+	data := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(ev.Data)
+	for _, line := range strings.Split(data, "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// singleLine drops line breaks, which would otherwise end the field early
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,101 @@
+package doze
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventStreamResponseFrames(t *testing.T) {
+	events := make(chan Event, 3)
+	events <- Event{ID: "1", Event: "progress", Data: "10%"}
+	events <- Event{Data: "line one\nline two", Retry: 2 * time.Second}
+	close(events)
+
+	router := Router("TestEventStreamResponseFrames")
+	router.Add(NewRoute().For("/events").With(http.MethodGet, func(c *Context) ResponseSender {
+		return EventStreamResponse{Events: events, Heartbeat: -1}
+	}))
+
+	resp := httptest.NewRecorder()
+	NewHandler(router).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	assert.Equal(t, http.StatusOK, resp.Code, "they should be equal")
+	assert.Equal(t, "text/event-stream", resp.Header().Get("Content-Type"), "they should be equal")
+	assert.Equal(t, "no-cache", resp.Header().Get("Cache-Control"), "they should be equal")
+	assert.Equal(t, "id: 1\nevent: progress\ndata: 10%\n\nretry: 2000\ndata: line one\ndata: line two\n\n", resp.Body.String(), "they should be equal")
+	assert.True(t, resp.Flushed, "response should be flushed")
+}
+
+func TestEventStreamResponseLastEventIDAndCancel(t *testing.T) {
+	done := make(chan struct{})
+	lastEventIDs := make(chan string, 1)
+
+	router := Router("TestEventStreamResponseLastEventIDAndCancel")
+	router.Add(NewRoute().For("/events").With(http.MethodGet, func(c *Context) ResponseSender {
+		es := c.EventStream(func(lastEventID string) <-chan Event {
+			lastEventIDs <- lastEventID
+
+			events := make(chan Event, 1)
+			events <- Event{ID: "43", Data: "resumed"}
+
+			return events
+		})
+		es.Heartbeat = 10 * time.Millisecond
+
+		return es
+	}))
+
+	h := NewHandler(router)
+	h.Use(func(c *Context, next NextFunc) {
+		next(c)
+		close(done)
+	})
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "42")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err, "error should be nil")
+
+	reader := bufio.NewReader(resp.Body)
+
+	var lines []string
+	for len(lines) < 4 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+
+	assert.Equal(t, "42", <-lastEventIDs, "they should be equal")
+	assert.Equal(t, []string{"id: 43", "data: resumed", "", ": heartbeat"}, lines, "they should be equal")
+
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream should stop when the request is cancelled")
+	}
+}
+
+func TestEventFrameLineEndings(t *testing.T) {
+	ev := Event{ID: "1\r\nid: 2", Data: "a\r\nb\rid: 99\nc"}
+
+	assert.Equal(t, "id: 1id: 2\ndata: a\ndata: b\ndata: id: 99\ndata: c\n\n", ev.frame(), "every line ending should start a new data line")
+}
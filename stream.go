@@ -0,0 +1,220 @@
+package doze
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// DefaultFlushSize is how many bytes a streaming response writes between flushes
+// when its FlushSize is 0
+const DefaultFlushSize = 32 * 1024
+
+// StreamResponse copies the body from Reader instead of holding it in memory.
+// If Reader is an io.Closer it is closed once sent
+type StreamResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Reader     io.Reader
+	FlushSize  int
+}
+
+// NDJSONResponse writes each value from Values as a line of JSON
+type NDJSONResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Values     func(yield func(interface{}) bool)
+	FlushSize  int
+}
+
+// CSVResponse writes an optional Header row followed by each row from Rows
+type CSVResponse struct {
+	StatusCode int
+	Headers    map[string]string
+	Header     []string
+	Rows       func(yield func([]string) bool)
+	FlushSize  int
+}
+
+// NewStreamResponse returns a StreamResponse with status code of 200
+func NewStreamResponse(contentType string, r io.Reader) StreamResponse {
+	return StreamResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Type": contentType},
+		Reader:     r,
+	}
+}
+
+// NewNDJSONResponse returns an NDJSONResponse with status code of 200 that writes
+// values until the channel is closed.  The producer should also stop on the
+// request's context being done, as the channel is not drained if writing fails
+func NewNDJSONResponse(ch <-chan interface{}) NDJSONResponse {
+	return NewNDJSONSeqResponse(func(yield func(interface{}) bool) {
+		for v := range ch {
+			if !yield(v) {
+				return
+			}
+		}
+	})
+}
+
+// NewNDJSONSeqResponse returns an NDJSONResponse with status code of 200 that
+// writes values from an iterator such as an iter.Seq[any]
+func NewNDJSONSeqResponse(seq func(yield func(interface{}) bool)) NDJSONResponse {
+	return NDJSONResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Type": "application/x-ndjson"},
+		Values:     seq,
+	}
+}
+
+// NewCSVResponse returns a CSVResponse with status code of 200 that writes rows
+// until the channel is closed.  The same caveat as NewNDJSONResponse applies
+func NewCSVResponse(header []string, rows <-chan []string) CSVResponse {
+	return NewCSVSeqResponse(header, func(yield func([]string) bool) {
+		for row := range rows {
+			if !yield(row) {
+				return
+			}
+		}
+	})
+}
+
+// NewCSVSeqResponse returns a CSVResponse with status code of 200 that writes
+// rows from an iterator such as an iter.Seq[[]string]
+func NewCSVSeqResponse(header []string, seq func(yield func([]string) bool)) CSVResponse {
+	return CSVResponse{
+		StatusCode: http.StatusOK,
+		Headers:    map[string]string{"Content-Type": "text/csv; charset=utf-8"},
+		Header:     header,
+		Rows:       seq,
+	}
+}
+
+// Send copies the Reader to the http.ResponseWriter
+func (sr StreamResponse) Send(w io.Writer) (int, error) {
+	if c, ok := sr.Reader.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	fw := newFlushWriter(w, sr.StatusCode, sr.Headers, sr.FlushSize)
+
+	_, err := io.Copy(fw, sr.Reader)
+
+	return fw.finish(err)
+}
+
+// Send writes every value as JSON followed by a newline
+func (nr NDJSONResponse) Send(w io.Writer) (int, error) {
+	fw := newFlushWriter(w, nr.StatusCode, nr.Headers, nr.FlushSize)
+	enc := json.NewEncoder(fw)
+
+	var err error
+	nr.Values(func(v interface{}) bool {
+		err = enc.Encode(v)
+
+		return err == nil
+	})
+
+	return fw.finish(err)
+}
+
+// Send writes the header and every row as CSV
+func (cr CSVResponse) Send(w io.Writer) (int, error) {
+	fw := newFlushWriter(w, cr.StatusCode, cr.Headers, cr.FlushSize)
+	cw := csv.NewWriter(fw)
+
+	var err error
+	if len(cr.Header) > 0 {
+		err = cw.Write(cr.Header)
+	}
+
+	if err == nil {
+		cr.Rows(func(row []string) bool {
+			err = cw.Write(row)
+
+			return err == nil
+		})
+	}
+
+	if err == nil {
+		cw.Flush()
+		err = cw.Error()
+	}
+
+	return fw.finish(err)
+}
+
+// flushWriter sends the headers on the first write and flushes the underlying
+// http.ResponseWriter, if it can, every size bytes
+type flushWriter struct {
+	w          io.Writer
+	statusCode int
+	headers    map[string]string
+	size       int
+	written    int
+	unflushed  int
+	started    bool
+}
+
+func newFlushWriter(w io.Writer, statusCode int, headers map[string]string, size int) *flushWriter {
+	if size <= 0 {
+		size = DefaultFlushSize
+	}
+
+	return &flushWriter{w: w, statusCode: statusCode, headers: headers, size: size}
+}
+
+func (fw *flushWriter) start() {
+	if fw.started {
+		return
+	}
+
+	fw.started = true
+
+	if rw, ok := fw.w.(ResponseWriter); ok {
+		BasicResponse{StatusCode: fw.statusCode, Headers: fw.headers}.setHeaders(rw)
+	}
+}
+
+func (fw *flushWriter) Write(b []byte) (int, error) {
+	fw.start()
+
+	n, err := fw.w.Write(b)
+
+	fw.written += n
+	fw.unflushed += n
+
+	if fw.unflushed >= fw.size {
+		fw.flush()
+	}
+
+	return n, err
+}
+
+func (fw *flushWriter) flush() {
+	fw.unflushed = 0
+
+	flush(fw.w)
+}
+
+// finish makes sure the headers are sent even for an empty body and flushes
+// whatever is left
+func (fw *flushWriter) finish(err error) (int, error) {
+	fw.start()
+
+	if err == nil && fw.unflushed > 0 {
+		fw.flush()
+	}
+
+	return fw.written, err
+}
+
+// flush sends any buffered data to the client if w is an http.ResponseWriter
+// that supports it
+func flush(w io.Writer) {
+	if rw, ok := w.(http.ResponseWriter); ok {
+		http.NewResponseController(rw).Flush()
+	}
+}
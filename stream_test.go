@@ -0,0 +1,113 @@
+package doze
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (fr *flushRecorder) Flush() {
+	fr.flushes++
+	fr.ResponseRecorder.Flush()
+}
+
+type closingReader struct {
+	io.Reader
+	closed bool
+}
+
+func (cr *closingReader) Close() error {
+	cr.closed = true
+
+	return nil
+}
+
+func TestStreamResponse(t *testing.T) {
+	fr := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	body := &closingReader{Reader: strings.NewReader(strings.Repeat("a", 100))}
+
+	sr := NewStreamResponse("application/octet-stream", body)
+	sr.FlushSize = 30
+
+	n, err := sr.Send(NewResponseWriter(fr))
+
+	assert.Nil(t, err, "error should be nil")
+	assert.Equal(t, 100, n, "they should be equal")
+	assert.Equal(t, http.StatusOK, fr.Code, "they should be equal")
+	assert.Equal(t, "application/octet-stream", fr.Header().Get("Content-Type"), "they should be equal")
+	assert.Equal(t, strings.Repeat("a", 100), fr.Body.String(), "they should be equal")
+	assert.Equal(t, 1, fr.flushes, "100 bytes copied at once should flush once")
+	assert.True(t, body.closed, "reader should be closed")
+}
+
+func TestNDJSONResponse(t *testing.T) {
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+
+		for i := 1; i <= 3; i++ {
+			ch <- TestStruct{strings.Repeat("x", i)}
+		}
+	}()
+
+	fr := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+	nr := NewNDJSONResponse(ch)
+	nr.FlushSize = 1
+
+	_, err := nr.Send(NewResponseWriter(fr))
+
+	assert.Nil(t, err, "error should be nil")
+	assert.Equal(t, "application/x-ndjson", fr.Header().Get("Content-Type"), "they should be equal")
+	assert.Equal(t, "{\"Message\":\"x\"}\n{\"Message\":\"xx\"}\n{\"Message\":\"xxx\"}\n", fr.Body.String(), "they should be equal")
+	assert.Equal(t, 3, fr.flushes, "every line should be flushed")
+}
+
+func TestCSVResponse(t *testing.T) {
+	router := Router("TestCSVResponse")
+	router.Add(NewRoute().For("/export").With(http.MethodGet, func(c *Context) ResponseSender {
+		return NewCSVSeqResponse([]string{"id", "name"}, func(yield func([]string) bool) {
+			for _, row := range [][]string{{"1", "Joe"}, {"2", "Smith, Jane"}} {
+				if !yield(row) {
+					return
+				}
+			}
+		})
+	}))
+
+	resp := httptest.NewRecorder()
+	NewHandler(router).ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/export", nil))
+
+	assert.Equal(t, http.StatusOK, resp.Code, "they should be equal")
+	assert.Equal(t, "text/csv; charset=utf-8", resp.Header().Get("Content-Type"), "they should be equal")
+	assert.Equal(t, "id,name\n1,Joe\n2,\"Smith, Jane\"\n", resp.Body.String(), "they should be equal")
+	assert.True(t, resp.Flushed, "response should be flushed")
+}
+
+func TestNDJSONResponseStopsOnError(t *testing.T) {
+	yielded := 0
+
+	nr := NewNDJSONSeqResponse(func(yield func(interface{}) bool) {
+		for i := 0; i < 5; i++ {
+			yielded++
+
+			if !yield(func() {}) {
+				return
+			}
+		}
+	})
+
+	_, err := nr.Send(NewResponseWriter(httptest.NewRecorder()))
+
+	assert.Error(t, err, "error should be returned")
+	assert.Equal(t, 1, yielded, "iteration should stop after the first error")
+}
@@ -0,0 +1,244 @@
+package doze
+
+import (
+	"strings"
+)
+
+type nodeKind uint8
+
+const (
+	staticNode nodeKind = iota
+	paramNode
+	catchAllNode
+)
+
+// node is a single edge in the routing tree.  Static nodes hold a literal chunk
+// of a path which is shared with every route below them, param nodes hold the
+// original {name:type} token and consume a variable amount of the path.
+//
+// Children are tried in order of precedence: static children first, then typed
+// params, then untyped params and finally catch-alls.  Params of the same kind
+// keep the order they were added in.  If a child cannot match the rest of the
+// path the next one is tried, so precedence is decided one segment at a time
+// from left to right and never depends on map iteration order
+type node struct {
+	kind    nodeKind
+	prefix  string
+	ptype   *paramType
+	statics []*node
+	params  []*node
+	route   Route
+}
+
+func newTree() *node {
+	return &node{kind: staticNode}
+}
+
+// insert adds the route under the remaining path.  An existing route with the
+// exact same path is replaced
+func (n *node) insert(path string, route Route) {
+	if path == "" {
+		n.route = route
+		return
+	}
+
+	loc := regParam.FindStringSubmatchIndex(path)
+	if loc == nil || loc[0] > 0 {
+		end := len(path)
+		if loc != nil {
+			end = loc[0]
+		}
+
+		n.insertStatic(path[:end], path[end:], route)
+		return
+	}
+
+	token := path[loc[0]:loc[1]]
+
+	var pType string
+	if loc[4] >= 0 {
+		pType = path[loc[4]+1 : loc[5]]
+	}
+
+	n.paramChild(token, pType).insert(path[loc[1]:], route)
+}
+
+func (n *node) insertStatic(text, rest string, route Route) {
+	for _, child := range n.statics {
+		l := commonPrefixLen(child.prefix, text)
+		if l == 0 {
+			continue
+		}
+
+		if l < len(child.prefix) {
+			child.split(l)
+		}
+
+		if l == len(text) {
+			child.insert(rest, route)
+		} else {
+			child.insertStatic(text[l:], rest, route)
+		}
+
+		return
+	}
+
+	child := &node{kind: staticNode, prefix: text}
+	n.statics = append(n.statics, child)
+
+	child.insert(rest, route)
+}
+
+func (n *node) paramChild(token, pType string) *node {
+	for _, child := range n.params {
+		if child.prefix == token {
+			return child
+		}
+	}
+
+	child := &node{kind: paramNode, prefix: token, ptype: lookupParamType(pType)}
+	if pType == catchAllParam {
+		child.kind = catchAllNode
+	}
+
+	// keep params sorted by precedence, after any existing param of the same rank
+	i := len(n.params)
+	for i > 0 && child.rank() < n.params[i-1].rank() {
+		i--
+	}
+
+	n.params = append(n.params, nil)
+	copy(n.params[i+1:], n.params[i:])
+	n.params[i] = child
+
+	return child
+}
+
+func (n *node) rank() int {
+	switch {
+	case n.kind == catchAllNode:
+		return 2
+	case n.ptype == nil:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// split breaks a static node in two at the given offset so that a new sibling
+// can share the first half
+func (n *node) split(at int) {
+	child := &node{
+		kind:    staticNode,
+		prefix:  n.prefix[at:],
+		statics: n.statics,
+		params:  n.params,
+		route:   n.route,
+	}
+
+	n.prefix = n.prefix[:at]
+	n.statics = []*node{child}
+	n.params = nil
+	n.route = nil
+}
+
+// lookup walks the tree for the remaining path and collects the raw param values
+// in the order they appear in the route path.  Params never cross a "/", catch-alls
+// do, and both are tried longest first the same way a greedy regex would behave
+func (n *node) lookup(path string, values []interface{}) (Route, []interface{}, bool) {
+	if path == "" {
+		return n.route, values, n.route != nil
+	}
+
+	for _, child := range n.statics {
+		if child.prefix[0] != path[0] {
+			continue
+		}
+
+		if strings.HasPrefix(path, child.prefix) {
+			if route, v, ok := child.lookup(path[len(child.prefix):], values); ok {
+				return route, v, true
+			}
+		}
+
+		break
+	}
+
+	if len(n.params) == 0 {
+		return nil, values, false
+	}
+
+	end := strings.IndexByte(path, '/')
+	if end < 0 {
+		end = len(path)
+	}
+
+	for _, child := range n.params {
+		end := end
+		if child.kind == catchAllNode {
+			end = len(path)
+		}
+
+		for i := end; i > 0; i-- {
+			if !child.canContinue(path[i:]) {
+				continue
+			}
+
+			value := path[:i]
+
+			if child.ptype != nil && !child.ptype.match(value) {
+				continue
+			}
+
+			route, v, ok := child.lookup(path[i:], append(values, value))
+			if !ok {
+				continue
+			}
+
+			// parsing can cost far more than the pattern, so it waits until the
+			// whole path has matched with this value
+			if child.ptype != nil && !child.ptype.parses(value) {
+				continue
+			}
+
+			return route, v, true
+		}
+	}
+
+	return nil, values, false
+}
+
+// canContinue reports whether the rest of the path could match below n, judging
+// by its first byte only.  It keeps lookup from matching a param against every
+// prefix of a long segment when nothing could follow most of them
+func (n *node) canContinue(rest string) bool {
+	if rest == "" {
+		return n.route != nil
+	}
+
+	if len(n.params) > 0 {
+		return true
+	}
+
+	for _, child := range n.statics {
+		if child.prefix[0] == rest[0] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func commonPrefixLen(a, b string) int {
+	max := len(a)
+	if len(b) < max {
+		max = len(b)
+	}
+
+	i := 0
+	for i < max && a[i] == b[i] {
+		i++
+	}
+
+	return i
+}
@@ -0,0 +1,352 @@
+package doze
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultMaxUploadSize is the largest multipart body read when
+// UploadOptions.MaxTotalSize is 0
+const DefaultMaxUploadSize = 32 << 20
+
+// sniffLen is how much of a file http.DetectContentType looks at
+const sniffLen = 512
+
+var safeExt = regexp.MustCompile(`^\.[A-Za-z0-9]{1,16}$`)
+
+// UploadOptions configures how StreamUploads and SaveUploads read a multipart
+// request
+type UploadOptions struct {
+	// MaxFileSize is the largest single file in bytes, only limited by
+	// MaxTotalSize when 0
+	MaxFileSize int64
+
+	// MaxTotalSize is the largest request body in bytes, DefaultMaxUploadSize
+	// when 0 and unlimited when negative
+	MaxTotalSize int64
+
+	// AllowedTypes lists the media types files may have, such as "image/png" or
+	// "image/*".  Types are sniffed from the content of each file, the type the
+	// client claims is ignored.  Any type is allowed when it is empty
+	AllowedTypes []string
+}
+
+// Upload is a file being streamed from a multipart request.  Read it to get the
+// content, which stops with a *FileTooLargeError past UploadOptions.MaxFileSize
+type Upload struct {
+	FieldName   string
+	FileName    string
+	ContentType string
+	Header      textproto.MIMEHeader
+
+	r    io.Reader
+	size int64
+}
+
+func (u *Upload) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	u.size += int64(n)
+
+	return n, err
+}
+
+// Size returns the number of bytes read so far
+func (u *Upload) Size() int64 {
+	return u.size
+}
+
+// SavedUpload describes a file saved by SaveUploads
+type SavedUpload struct {
+	FieldName   string
+	FileName    string
+	ContentType string
+	Size        int64
+	Location    string
+}
+
+// UploadStorage saves uploaded files somewhere, such as a directory or an object
+// store, and returns where the file can be found
+type UploadStorage interface {
+	Save(ctx context.Context, u *Upload) (string, error)
+}
+
+// DirStorage is an UploadStorage that saves every upload as a new file in Dir, or
+// in os.TempDir when Dir is empty.  Files get a random name that keeps the
+// extension of the uploaded file name, the name itself is never used as a path.
+// The returned location is the path of the file
+type DirStorage struct {
+	Dir string
+}
+
+// Save copies the upload to a new file, which is removed again if reading the
+// upload fails
+func (s DirStorage) Save(ctx context.Context, u *Upload) (string, error) {
+	ext := filepath.Ext(u.FileName)
+	if !safeExt.MatchString(ext) {
+		ext = ""
+	}
+
+	f, err := os.CreateTemp(s.Dir, "upload-*"+ext)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(f, u)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// TempStorage returns a DirStorage for dir whose files are removed when the
+// request ends, for uploads that only need to live while the action processes
+// them
+func (c *Context) TempStorage(dir string) UploadStorage {
+	return tempStorage{c, DirStorage{dir}}
+}
+
+type tempStorage struct {
+	c *Context
+	DirStorage
+}
+
+func (s tempStorage) Save(ctx context.Context, u *Upload) (string, error) {
+	location, err := s.DirStorage.Save(ctx, u)
+	if err == nil {
+		s.c.Cleanup(func() { os.Remove(location) })
+	}
+
+	return location, err
+}
+
+// FileTooLargeError is returned when a single file is larger than
+// UploadOptions.MaxFileSize.  It is answered with 413 Request Entity Too Large
+type FileTooLargeError struct {
+	FieldName string
+	FileName  string
+	Limit     int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("file %q in %q is larger than %d bytes", e.FileName, e.FieldName, e.Limit)
+}
+
+func (e *FileTooLargeError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// UploadTypeError is returned when a file's sniffed media type is not in
+// UploadOptions.AllowedTypes.  It is answered with 415 Unsupported Media Type
+type UploadTypeError struct {
+	FieldName   string
+	FileName    string
+	ContentType string
+}
+
+func (e *UploadTypeError) Error() string {
+	return fmt.Sprintf("file %q in %q has unsupported type %v", e.FileName, e.FieldName, e.ContentType)
+}
+
+func (e *UploadTypeError) StatusCode() int {
+	return http.StatusUnsupportedMediaType
+}
+
+// StreamUploads reads a multipart/form-data request one part at a time without
+// buffering it in memory or on disk.  fn is called for every file in the order
+// they were sent and must read what it needs before returning, as the next part
+// replaces it.  The other form fields are returned once the body is read.
+//
+// A request that is not multipart returns ErrUnsupportedMediaType, a malformed
+// body an error wrapping ErrBadRequest and a body over MaxTotalSize a
+// *BodyTooLargeError.  Files are checked against opts as described on
+// UploadOptions, and any error returned by fn stops reading and is returned as
+// it is
+func (c *Context) StreamUploads(opts UploadOptions, fn func(*Upload) error) (url.Values, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.Request.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	limit := opts.MaxTotalSize
+	if limit == 0 {
+		limit = DefaultMaxUploadSize
+	}
+
+	if limit > 0 {
+		c.Request.Body = http.MaxBytesReader(c.ResponseWriter, c.Request.Body, limit)
+	}
+
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadRequest, err)
+	}
+
+	values := url.Values{}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return values, nil
+		}
+
+		if err != nil {
+			return nil, uploadError(err)
+		}
+
+		if part.FileName() == "" {
+			b, err := io.ReadAll(part)
+			if err != nil {
+				return nil, uploadError(err)
+			}
+
+			values.Add(part.FormName(), string(b))
+
+			continue
+		}
+
+		u, err := newUpload(part.FormName(), part.FileName(), part.Header, part, opts)
+		if err != nil {
+			return nil, uploadError(err)
+		}
+
+		if err := fn(u); err != nil {
+			return nil, uploadError(err)
+		}
+	}
+}
+
+// SaveUploads streams every file of a multipart/form-data request to storage
+// and returns what was saved along with the other form fields.  It fails the
+// same way as StreamUploads.  Files saved before an error are returned with it,
+// so the caller can remove them from storage
+func (c *Context) SaveUploads(storage UploadStorage, opts UploadOptions) ([]SavedUpload, url.Values, error) {
+	var saved []SavedUpload
+
+	values, err := c.StreamUploads(opts, func(u *Upload) error {
+		location, err := storage.Save(c.Request.Context(), u)
+		if err != nil {
+			return err
+		}
+
+		saved = append(saved, SavedUpload{
+			FieldName:   u.FieldName,
+			FileName:    u.FileName,
+			ContentType: u.ContentType,
+			Size:        u.Size(),
+			Location:    location,
+		})
+
+		return nil
+	})
+
+	return saved, values, err
+}
+
+func newUpload(fieldName, fileName string, header textproto.MIMEHeader, r io.Reader, opts UploadOptions) (*Upload, error) {
+	if opts.MaxFileSize > 0 {
+		r = &limitedUpload{r: r, n: opts.MaxFileSize, err: &FileTooLargeError{fieldName, fileName, opts.MaxFileSize}}
+	}
+
+	br := bufio.NewReaderSize(r, sniffLen)
+
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	u := &Upload{
+		FieldName:   fieldName,
+		FileName:    fileName,
+		ContentType: http.DetectContentType(head),
+		Header:      header,
+		r:           br,
+	}
+
+	if !typeAllowed(u.ContentType, opts.AllowedTypes) {
+		return nil, &UploadTypeError{fieldName, fileName, u.ContentType}
+	}
+
+	return u, nil
+}
+
+func typeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+
+		if a == mediaType || strings.HasSuffix(a, "/*") && strings.HasPrefix(mediaType, a[:len(a)-1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// uploadError turns an oversized body into a *BodyTooLargeError and a malformed
+// one into ErrBadRequest, leaving the errors of this file and fn as they are
+func uploadError(err error) error {
+	var (
+		sizeErr *http.MaxBytesError
+		sc      StatusCoder
+	)
+
+	switch {
+	case errors.As(err, &sizeErr):
+		return &BodyTooLargeError{Limit: sizeErr.Limit}
+	case errors.As(err, &sc):
+		return err
+	case err == io.ErrUnexpectedEOF || strings.HasPrefix(err.Error(), "multipart: "):
+		return fmt.Errorf("%w: %v", ErrBadRequest, err)
+	}
+
+	return err
+}
+
+// limitedUpload is like io.LimitedReader but fails instead of stopping early
+type limitedUpload struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitedUpload) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, l.err
+	}
+
+	// read one byte past the limit to tell a file of exactly n bytes apart
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+
+	if l.n < 0 {
+		return n + int(l.n), l.err
+	}
+
+	return n, err
+}
@@ -0,0 +1,213 @@
+package doze
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+type uploadPart struct {
+	field, file string
+	content     []byte
+}
+
+func multipartRequest(parts ...uploadPart) *http.Request {
+	var body bytes.Buffer
+
+	mw := multipart.NewWriter(&body)
+	for _, p := range parts {
+		if p.file == "" {
+			mw.WriteField(p.field, string(p.content))
+			continue
+		}
+
+		w, _ := mw.CreateFormFile(p.field, p.file)
+		w.Write(p.content)
+	}
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/uploads", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	return req
+}
+
+func TestSaveUploads(t *testing.T) {
+	dir := t.TempDir()
+
+	var (
+		saved  []SavedUpload
+		values url.Values
+		err    error
+		during []bool
+	)
+
+	router := Router("TestSaveUploads")
+	router.Add(NewRoute().For("/uploads").With(http.MethodPost, func(c *Context) ResponseSender {
+		saved, values, err = c.SaveUploads(c.TempStorage(dir), UploadOptions{AllowedTypes: []string{"image/png", "text/*"}})
+
+		for _, s := range saved {
+			_, statErr := os.Stat(s.Location)
+			during = append(during, statErr == nil)
+		}
+
+		return NewNoContentResponse()
+	}))
+
+	h := NewHandler(router)
+
+	png := append(pngHeader, bytes.Repeat([]byte{0}, 1000)...)
+
+	h.ServeHTTP(httptest.NewRecorder(), multipartRequest(
+		uploadPart{"title", "", []byte("Holiday")},
+		uploadPart{"photo", "beach.png", png},
+		uploadPart{"notes", "../../etc/notes.txt", []byte("hello")},
+	))
+
+	assert.Nil(t, err, "should be nil")
+	assert.Equal(t, url.Values{"title": {"Holiday"}}, values, "they should be equal")
+	assert.Len(t, saved, 2, "should have saved two files")
+
+	assert.Equal(t, "photo", saved[0].FieldName, "they should be equal")
+	assert.Equal(t, "beach.png", saved[0].FileName, "they should be equal")
+	assert.Equal(t, "image/png", saved[0].ContentType, "they should be equal")
+	assert.Equal(t, int64(len(png)), saved[0].Size, "they should be equal")
+	assert.Equal(t, ".png", filepath.Ext(saved[0].Location), "they should be equal")
+
+	assert.Equal(t, "notes.txt", saved[1].FileName, "they should be equal")
+	assert.Equal(t, "text/plain; charset=utf-8", saved[1].ContentType, "they should be equal")
+	assert.Equal(t, dir, filepath.Dir(saved[1].Location), "should stay in the storage directory")
+
+	assert.Equal(t, []bool{true, true}, during, "files should exist while handling the request")
+
+	for _, s := range saved {
+		_, statErr := os.Stat(s.Location)
+		assert.True(t, os.IsNotExist(statErr), "temporary files should be removed")
+	}
+}
+
+func TestDirStorageKeepsFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	var saved []SavedUpload
+
+	router := Router("TestDirStorageKeepsFiles")
+	router.Add(NewRoute().For("/uploads").With(http.MethodPost, func(c *Context) ResponseSender {
+		saved, _, _ = c.SaveUploads(DirStorage{dir}, UploadOptions{})
+		return NewNoContentResponse()
+	}))
+
+	NewHandler(router).ServeHTTP(httptest.NewRecorder(), multipartRequest(uploadPart{"doc", "a.txt", []byte("kept")}))
+
+	assert.Len(t, saved, 1, "should have saved one file")
+
+	b, err := os.ReadFile(saved[0].Location)
+
+	assert.Nil(t, err, "should be nil")
+	assert.Equal(t, "kept", string(b), "they should be equal")
+}
+
+func TestStreamUploads(t *testing.T) {
+	var files []string
+
+	router := Router("TestStreamUploads")
+	router.Add(NewRoute().For("/uploads").With(http.MethodPost, ErrorAction(func(c *Context) (ResponseSender, error) {
+		_, err := c.StreamUploads(UploadOptions{MaxFileSize: 10}, func(u *Upload) error {
+			b, err := io.ReadAll(u)
+			files = append(files, fmt.Sprintf("%v=%s", u.FileName, b))
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return NewNoContentResponse(), nil
+	})))
+
+	h := NewHandler(router)
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, multipartRequest(uploadPart{"a", "a.txt", []byte("0123456789")}, uploadPart{"b", "b.txt", []byte("b")}))
+
+	assert.Equal(t, http.StatusNoContent, resp.Code, "they should be equal")
+	assert.Equal(t, []string{"a.txt=0123456789", "b.txt=b"}, files, "a file at the limit should be allowed")
+}
+
+func TestUploadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		opts UploadOptions
+		req  *http.Request
+		code int
+		err  string
+	}{
+		{UploadOptions{MaxFileSize: 10}, multipartRequest(uploadPart{"a", "a.txt", []byte("01234567890")}), http.StatusRequestEntityTooLarge, `file "a.txt" in "a" is larger than 10 bytes`},
+		{UploadOptions{MaxTotalSize: 100}, multipartRequest(uploadPart{"a", "a.txt", bytes.Repeat([]byte("a"), 200)}), http.StatusRequestEntityTooLarge, "request body is larger than 100 bytes"},
+		{UploadOptions{AllowedTypes: []string{"image/*"}}, multipartRequest(uploadPart{"a", "a.png", []byte("not really a png")}), http.StatusUnsupportedMediaType, `file "a.png" in "a" has unsupported type text/plain; charset=utf-8`},
+		{UploadOptions{}, httptest.NewRequest(http.MethodPost, "/uploads", strings.NewReader("{}")), http.StatusUnsupportedMediaType, "Unsupported Media Type"},
+	}
+
+	malformed := httptest.NewRequest(http.MethodPost, "/uploads", strings.NewReader("--x\r\nContent-Disposition: form-data; name=\"a\"; filename=\"a.txt\"\r\n\r\ntruncated"))
+	malformed.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+
+	tests = append(tests, struct {
+		opts UploadOptions
+		req  *http.Request
+		code int
+		err  string
+	}{UploadOptions{}, malformed, http.StatusBadRequest, "Bad Request: unexpected EOF"})
+
+	for i, test := range tests {
+		var err error
+
+		router := Router(fmt.Sprintf("TestUploadErrors_%d", i))
+		router.Add(NewRoute().For("/uploads").With(http.MethodPost, ErrorAction(func(c *Context) (ResponseSender, error) {
+			_, _, err = c.SaveUploads(DirStorage{dir}, test.opts)
+			return nil, err
+		})))
+
+		resp := httptest.NewRecorder()
+		NewHandler(router).ServeHTTP(resp, test.req)
+
+		msg := fmt.Sprintf("they should be equal for test %v", i)
+
+		assert.Equal(t, test.code, resp.Code, msg)
+		assert.Equal(t, test.err, err.Error(), msg)
+	}
+
+	entries, _ := os.ReadDir(dir)
+
+	assert.Empty(t, entries, "failed uploads should not leave files behind")
+}
+
+func TestContextCleanup(t *testing.T) {
+	var order []int
+
+	router := Router("TestContextCleanup")
+	router.Add(NewRoute().For("/panic").With(http.MethodGet, func(c *Context) ResponseSender {
+		c.Cleanup(func() { order = append(order, 1) })
+		c.Cleanup(func() { order = append(order, 2) })
+
+		panic(errors.New("boom"))
+	}))
+
+	assert.Panics(t, func() {
+		NewHandler(router).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	}, "should panic")
+
+	assert.Equal(t, []int{2, 1}, order, "they should be equal")
+}
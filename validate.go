@@ -0,0 +1,278 @@
+package doze
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ValidatorFunc checks a field value against the param of a validate rule, such
+// as "1" for min=1, and returns an error describing why the value is invalid.
+// Pointers are dereferenced before it is called
+type ValidatorFunc func(value reflect.Value, param string) error
+
+var (
+	validators = map[string]ValidatorFunc{
+		"required": validateRequired,
+		"min":      validateMin,
+		"max":      validateMax,
+		"email":    validateEmail,
+		"oneof":    validateOneOf,
+	}
+	validatorsLock sync.RWMutex
+)
+
+// RegisterValidator adds a rule that can be used in validate tags, or replaces
+// an existing one
+func RegisterValidator(name string, fn ValidatorFunc) {
+	validatorsLock.Lock()
+	defer validatorsLock.Unlock()
+
+	validators[name] = fn
+}
+
+func lookupValidator(name string) (ValidatorFunc, bool) {
+	validatorsLock.RLock()
+	defer validatorsLock.RUnlock()
+
+	fn, ok := validators[name]
+
+	return fn, ok
+}
+
+// Validate checks the fields of the struct v, or v points to, against the rules
+// in their validate tags, for example `validate:"required,min=1,max=100"`.
+// Rules are checked in order and a field fails on its first broken rule.  The
+// built in rules are:
+//   - required: not the zero value, and not empty for strings, slices and maps
+//   - min=n, max=n: a lower or upper bound on a number, the length of a string
+//     in characters or the length of a slice or map
+//   - email: a plain email address such as mary@example.com
+//   - oneof=a b c: one of the space separated values
+//
+// An omitempty rule skips the rest of the rules when the field is the zero
+// value, and a nil pointer is only checked by required.  Nested structs and
+// slices of structs are validated as well.  Fields are reported by their json
+// or binding tag name if they have one, nested fields joined with dots.
+//
+// Every field that fails is collected in a *ValidationError, which is answered
+// with 422 Unprocessable Entity.  An unknown rule is returned as a plain error
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("doze: Validate needs a struct, got %T", v)
+	}
+
+	ve := &ValidationError{}
+
+	if err := validateStruct(rv, "", ve); err != nil {
+		return err
+	}
+
+	return ve.Err()
+}
+
+func validateStruct(rv reflect.Value, prefix string, ve *ValidationError) error {
+	t := rv.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := rv.Field(i)
+
+		tag := sf.Tag.Get("validate")
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && tag == "" {
+			if err := validateStruct(fv, prefix, ve); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if sf.PkgPath != "" || tag == "-" {
+			continue
+		}
+
+		name := prefix + fieldName(sf)
+
+		if tag != "" {
+			if err := validateField(fv, tag, name, ve); err != nil {
+				return err
+			}
+		}
+
+		if err := validateNested(fv, name, ve); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateNested(v reflect.Value, name string, ve *ValidationError) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return validateStruct(v, name+".", ve)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateNested(v.Index(i), fmt.Sprintf("%v[%d]", name, i), ve); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateField(v reflect.Value, tag, name string, ve *ValidationError) error {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	isNil := v.Kind() == reflect.Ptr
+
+	for _, rule := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		if rule == "omitempty" {
+			if isEmpty(v) {
+				return nil
+			}
+
+			continue
+		}
+
+		fn, ok := lookupValidator(rule)
+		if !ok {
+			return fmt.Errorf("doze: unknown validate rule %q on %v", rule, name)
+		}
+
+		if isNil && rule != "required" {
+			continue
+		}
+
+		if err := fn(v, param); err != nil {
+			ve.Add(name, err.Error())
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// fieldName is the name a client knows the field by
+func fieldName(sf reflect.StructField) string {
+	for _, key := range append([]string{"json"}, bindSources...) {
+		name, _, _ := strings.Cut(sf.Tag.Get(key), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return sf.Name
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+
+	return v.IsZero()
+}
+
+func validateRequired(v reflect.Value, _ string) error {
+	if isEmpty(v) {
+		return errors.New("is required")
+	}
+
+	return nil
+}
+
+func validateMin(v reflect.Value, param string) error {
+	return validateBound(v, param, "min", func(n, bound float64) bool { return n >= bound }, "at least")
+}
+
+func validateMax(v reflect.Value, param string) error {
+	return validateBound(v, param, "max", func(n, bound float64) bool { return n <= bound }, "at most")
+}
+
+func validateBound(v reflect.Value, param, rule string, ok func(n, bound float64) bool, desc string) error {
+	bound, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Errorf("has an invalid %v rule %q", rule, param)
+	}
+
+	var (
+		n    float64
+		unit string
+	)
+
+	switch v.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		n, unit = float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		return fmt.Errorf("cannot check %v of %v", rule, v.Type())
+	}
+
+	if !ok(n, bound) {
+		if unit != "" {
+			return fmt.Errorf("must have %v %v%v", desc, param, unit)
+		}
+
+		return fmt.Errorf("must be %v %v", desc, param)
+	}
+
+	return nil
+}
+
+func validateEmail(v reflect.Value, _ string) error {
+	if v.Kind() != reflect.String {
+		return fmt.Errorf("cannot check email of %v", v.Type())
+	}
+
+	addr, err := mail.ParseAddress(v.String())
+	if err != nil || addr.Address != v.String() {
+		return errors.New("must be a valid email address")
+	}
+
+	return nil
+}
+
+func validateOneOf(v reflect.Value, param string) error {
+	options := strings.Fields(param)
+	value := fmt.Sprint(v.Interface())
+
+	for _, option := range options {
+		if value == option {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("must be one of %v", strings.Join(options, ", "))
+}
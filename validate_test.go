@@ -0,0 +1,140 @@
+package doze
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ValidateTestAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip" validate:"omitempty,min=5,max=5"`
+}
+
+type ValidateTestItem struct {
+	SKU string `json:"sku" validate:"required"`
+}
+
+type ValidateTestRequest struct {
+	Name     string              `json:"name" validate:"required,min=2,max=10"`
+	Email    string              `json:"email" validate:"required,email"`
+	Role     string              `json:"role" validate:"oneof=admin user"`
+	Age      *int                `json:"age" validate:"min=18"`
+	Nickname *string             `json:"nickname" validate:"required"`
+	Tags     []string            `json:"tags" validate:"max=2"`
+	Limit    int                 `query:"limit" validate:"min=1,max=100"`
+	Address  ValidateTestAddress `json:"address"`
+	Items    []ValidateTestItem  `json:"items" validate:"required"`
+	Note     string              `validate:"-"`
+}
+
+func validRequest() ValidateTestRequest {
+	nickname := "m"
+
+	return ValidateTestRequest{
+		Name:     "Mary",
+		Email:    "mary@example.com",
+		Role:     "admin",
+		Nickname: &nickname,
+		Limit:    10,
+		Address:  ValidateTestAddress{City: "Oslo"},
+		Items:    []ValidateTestItem{{"a-1"}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	req := validRequest()
+
+	assert.Nil(t, Validate(req), "should be nil")
+	assert.Nil(t, Validate(&req), "should be nil")
+
+	age := 17
+
+	req = ValidateTestRequest{
+		Name:    "M",
+		Email:   "Mary <mary@example.com>",
+		Role:    "root",
+		Age:     &age,
+		Tags:    []string{"a", "b", "c"},
+		Limit:   500,
+		Address: ValidateTestAddress{Zip: "123"},
+		Items:   []ValidateTestItem{{"a-1"}, {}},
+	}
+
+	err := Validate(&req)
+
+	var ve *ValidationError
+
+	assert.True(t, errors.As(err, &ve), "should be a ValidationError")
+	assert.Equal(t, http.StatusUnprocessableEntity, ve.StatusCode(), "they should be equal")
+	assert.Equal(t, []FieldError{
+		{"name", "must have at least 2 characters"},
+		{"email", "must be a valid email address"},
+		{"role", "must be one of admin, user"},
+		{"age", "must be at least 18"},
+		{"nickname", "is required"},
+		{"tags", "must have at most 2 items"},
+		{"limit", "must be at most 100"},
+		{"address.city", "is required"},
+		{"address.zip", "must have at least 5 characters"},
+		{"items[1].sku", "is required"},
+	}, ve.Fields, "they should be equal")
+}
+
+func TestValidateEmbedded(t *testing.T) {
+	type Page struct {
+		Limit int `query:"limit" validate:"min=1"`
+	}
+
+	type Request struct {
+		Page
+		Query string `query:"q" validate:"required"`
+	}
+
+	err := Validate(Request{})
+
+	var ve *ValidationError
+
+	assert.True(t, errors.As(err, &ve), "should be a ValidationError")
+	assert.Equal(t, []FieldError{{"limit", "must be at least 1"}, {"q", "is required"}}, ve.Fields, "they should be equal")
+}
+
+func TestRegisterValidator(t *testing.T) {
+	RegisterValidator("prefix", func(v reflect.Value, param string) error {
+		if !strings.HasPrefix(v.String(), param) {
+			return fmt.Errorf("must start with %v", param)
+		}
+
+		return nil
+	})
+
+	type Request struct {
+		SKU string `json:"sku" validate:"required,prefix=sku-"`
+	}
+
+	assert.Nil(t, Validate(Request{"sku-1"}), "should be nil")
+
+	var ve *ValidationError
+
+	assert.True(t, errors.As(Validate(Request{"1"}), &ve), "should be a ValidationError")
+	assert.Equal(t, []FieldError{{"sku", "must start with sku-"}}, ve.Fields, "they should be equal")
+}
+
+func TestValidateUnknownRule(t *testing.T) {
+	type Request struct {
+		Name string `validate:"required,shiny"`
+	}
+
+	err := Validate(Request{"x"})
+
+	var ve *ValidationError
+
+	assert.NotNil(t, err, "should not be nil")
+	assert.False(t, errors.As(err, &ve), "should not be a ValidationError")
+	assert.NotNil(t, Validate("not a struct"), "should not be nil")
+}
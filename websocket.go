@@ -0,0 +1,587 @@
+package doze
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types of a WebSocket frame, as defined by RFC 6455
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// Close codes used by Conn, as defined by RFC 6455
+const (
+	CloseNormalClosure    = 1000
+	CloseGoingAway        = 1001
+	CloseProtocolError    = 1002
+	CloseUnsupportedData  = 1003
+	CloseNoStatusReceived = 1005
+	CloseInvalidPayload   = 1007
+	ClosePolicyViolation  = 1008
+	CloseMessageTooBig    = 1009
+	CloseInternalError    = 1011
+)
+
+// DefaultReadLimit is the largest message a Conn reads when the Upgrader has
+// no ReadLimit
+const DefaultReadLimit = 1 << 20
+
+const (
+	websocketGUID       = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	maxControlPayload   = 125
+	closeHandshakeLimit = time.Second
+)
+
+// ErrCloseSent is returned when writing to a Conn after its close frame was sent
+var ErrCloseSent = errors.New("doze: websocket close frame already sent")
+
+// CloseError is returned by Conn.ReadMessage once the peer closes the connection
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("doze: websocket closed with code %v %v", e.Code, e.Text)
+}
+
+// WebSocketFunc is a type that is a function to be used as a WebSocket action.
+// The Conn is closed once it returns
+type WebSocketFunc func(*Context, *Conn)
+
+// Upgrader upgrades requests to WebSocket connections
+type Upgrader struct {
+	// CheckOrigin decides if the Origin of the request is allowed.  When nil,
+	// requests with an Origin header must come from the same host
+	CheckOrigin func(*http.Request) bool
+
+	// ReadLimit is the largest message the Conn reads, DefaultReadLimit when 0
+	ReadLimit int64
+}
+
+// WebSocket adds a GET action that upgrades the request to a WebSocket and calls
+// fn with the connection.  Middleware and route params are handled before the
+// upgrade like for any other action
+func (r *DozeRoute) WebSocket(fn WebSocketFunc, mw ...MiddlewareFunc) *DozeRoute {
+	return r.With(http.MethodGet, Upgrader{}.Action(fn), mw...)
+}
+
+// Action returns an ActionFunc that upgrades the request and calls fn with the
+// connection.  Requests that cannot be upgraded get an error response instead
+func (u Upgrader) Action(fn WebSocketFunc) ActionFunc {
+	return func(c *Context) ResponseSender {
+		conn, rs := u.upgrade(c)
+		if rs != nil {
+			return rs
+		}
+
+		defer conn.Close()
+
+		fn(c, conn)
+
+		return nil
+	}
+}
+
+// Upgrade performs the opening handshake and takes over the connection.  Nothing
+// is written to the response if it fails
+func (u Upgrader) Upgrade(c *Context) (*Conn, error) {
+	conn, rs := u.upgrade(c)
+	if rs != nil {
+		return nil, fmt.Errorf("doze: websocket upgrade failed: %s", rs.Body)
+	}
+
+	return conn, nil
+}
+
+func (u Upgrader) upgrade(c *Context) (*Conn, *BasicResponse) {
+	r := c.Request
+
+	fail := func(status int, msg string, headers map[string]string) (*Conn, *BasicResponse) {
+		return nil, &BasicResponse{StatusCode: status, Headers: headers, Body: []byte(msg)}
+	}
+
+	upgradeRequired := map[string]string{"Upgrade": "websocket", "Sec-WebSocket-Version": "13"}
+
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "websocket handshake requires GET", map[string]string{"Allow": http.MethodGet})
+	}
+
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		return fail(http.StatusUpgradeRequired, "websocket handshake requires Upgrade: websocket", upgradeRequired)
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return fail(http.StatusUpgradeRequired, "unsupported websocket version", upgradeRequired)
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key", nil)
+	}
+
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+
+	if !checkOrigin(r) {
+		return fail(http.StatusForbidden, "websocket origin not allowed", nil)
+	}
+
+	hj, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return fail(http.StatusInternalServerError, "websocket upgrade not supported", nil)
+	}
+
+	netConn, brw, err := hj.Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, "websocket upgrade not supported", nil)
+	}
+
+	// net/http may have set deadlines for the request, which no longer apply
+	netConn.SetDeadline(time.Time{})
+
+	header := c.ResponseWriter.Header().Clone()
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", acceptKey(key))
+
+	// Header.Write drops invalid names and turns newlines in values into spaces,
+	// as net/http does, so a header set from request input cannot split the response
+	var sb strings.Builder
+	sb.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	header.Write(&sb)
+	sb.WriteString("\r\n")
+
+	if _, err := io.WriteString(netConn, sb.String()); err != nil {
+		netConn.Close()
+		return fail(http.StatusInternalServerError, "websocket handshake failed", nil)
+	}
+
+	if rw := responseOf(c.ResponseWriter); rw != nil {
+		rw.status = http.StatusSwitchingProtocols
+	}
+
+	conn := newConn(netConn, brw.Reader, true)
+	if u.ReadLimit > 0 {
+		conn.readLimit = u.ReadLimit
+	}
+
+	return conn, nil
+}
+
+// Conn is a WebSocket connection.  One goroutine may read while others write,
+// writes are serialised.  Close reads until the peer answers the close frame, so
+// it must not be called while another goroutine is in ReadMessage
+type Conn struct {
+	conn      net.Conn
+	br        *bufio.Reader
+	isServer  bool
+	readLimit int64
+
+	writeMu   sync.Mutex
+	closeSent bool
+	closeRecv bool
+	closeOnce sync.Once
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, isServer bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+
+	return &Conn{conn: conn, br: br, isServer: isServer, readLimit: DefaultReadLimit}
+}
+
+// RemoteAddr returns the address of the peer
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadDeadline sets the deadline for reading the next message
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// ReadMessage returns the next text or binary message, joining fragmented
+// messages.  Pings are answered and pongs dropped while waiting.  When the peer
+// closes the connection the close is acknowledged and a *CloseError returned
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var (
+		messageType int
+		message     []byte
+	)
+
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case PingMessage:
+			if err := c.writeFrame(true, PongMessage, payload); err != nil && err != ErrCloseSent {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "new message before the last one finished")
+			}
+			messageType = op
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "continuation without a message")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		if int64(len(message)+len(payload)) > c.readLimit {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+
+		message = append(message, payload...)
+
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(CloseInvalidPayload, "invalid utf-8")
+			}
+
+			return messageType, message, nil
+		}
+	}
+}
+
+// WriteMessage sends a text or binary message in a single frame
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("doze: invalid websocket message type %v", messageType)
+	}
+
+	return c.writeFrame(true, messageType, data)
+}
+
+// Writer returns a writer for a fragmented message.  Every Write sends a frame
+// and Close sends the final one, so large messages never have to be held in
+// memory.  No other message can be written until the writer is closed
+func (c *Conn) Writer(messageType int) (io.WriteCloser, error) {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return nil, fmt.Errorf("doze: invalid websocket message type %v", messageType)
+	}
+
+	c.writeMu.Lock()
+
+	return &fragmentWriter{c: c, op: messageType}, nil
+}
+
+// Ping sends a ping with up to 125 bytes of data, the pong is dropped by
+// ReadMessage
+func (c *Conn) Ping(data []byte) error {
+	return c.writeFrame(true, PingMessage, data)
+}
+
+// CloseWithCode starts the close handshake with the code and reason, waits a
+// short time for the peer to answer and then closes the connection
+func (c *Conn) CloseWithCode(code int, reason string) error {
+	err := c.writeClose(code, reason)
+
+	c.closeOnce.Do(func() {
+		if !c.closeRecv {
+			c.conn.SetReadDeadline(time.Now().Add(closeHandshakeLimit))
+
+			for {
+				_, op, _, err := c.readFrame()
+				if err != nil {
+					break
+				}
+
+				if op == CloseMessage {
+					c.closeRecv = true
+					break
+				}
+			}
+		}
+
+		c.conn.Close()
+	})
+
+	if err == ErrCloseSent {
+		return nil
+	}
+
+	return err
+}
+
+// Close closes the connection with a normal closure
+func (c *Conn) Close() error {
+	return c.CloseWithCode(CloseNormalClosure, "")
+}
+
+func (c *Conn) handleClose(payload []byte) error {
+	c.closeRecv = true
+
+	ce := &CloseError{Code: CloseNoStatusReceived}
+
+	switch {
+	case len(payload) == 1:
+		c.fail(CloseProtocolError, "invalid close payload")
+		return ce
+	case len(payload) >= 2:
+		ce.Code = int(binary.BigEndian.Uint16(payload))
+		ce.Text = string(payload[2:])
+
+		if !utf8.Valid(payload[2:]) {
+			c.fail(CloseInvalidPayload, "invalid utf-8")
+			return ce
+		}
+	}
+
+	code := ce.Code
+	if code == CloseNoStatusReceived {
+		code = CloseNormalClosure
+	}
+
+	c.writeClose(code, "")
+	c.closeOnce.Do(func() { c.conn.Close() })
+
+	return ce
+}
+
+// fail closes the connection after a protocol violation by the peer
+func (c *Conn) fail(code int, reason string) error {
+	c.writeClose(code, reason)
+	c.closeOnce.Do(func() { c.conn.Close() })
+
+	return &CloseError{Code: code, Text: reason}
+}
+
+func (c *Conn) writeClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+
+	return c.writeFrame(true, CloseMessage, payload)
+}
+
+func (c *Conn) readFrame() (bool, int, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := head[0]&0x80 != 0
+	op := int(head[0] & 0x0f)
+	masked := head[1]&0x80 != 0
+	length := int64(head[1] & 0x7f)
+
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "reserved bits set")
+	}
+
+	// clients must mask every frame, servers must never mask
+	if masked != c.isServer {
+		return false, 0, nil, c.fail(CloseProtocolError, "bad frame masking")
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		if ext[0]&0x80 != 0 {
+			return false, 0, nil, c.fail(CloseProtocolError, "invalid frame length")
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if op >= CloseMessage && (!fin || length > maxControlPayload) {
+		return false, 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+	}
+
+	if length > c.readLimit {
+		return false, 0, nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var maskKey [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, maskKey[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	if masked {
+		maskBytes(maskKey, payload)
+	}
+
+	return fin, op, payload, nil
+}
+
+func (c *Conn) writeFrame(fin bool, op int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.writeFrameLocked(fin, op, payload)
+}
+
+func (c *Conn) writeFrameLocked(fin bool, op int, payload []byte) error {
+	if c.closeSent {
+		return ErrCloseSent
+	}
+
+	if op == CloseMessage {
+		c.closeSent = true
+	}
+
+	frame := make([]byte, 0, 14+len(payload))
+
+	b0 := byte(op)
+	if fin {
+		b0 |= 0x80
+	}
+
+	var maskBit byte
+	if !c.isServer {
+		maskBit = 0x80
+	}
+
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, b0, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, b0, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	default:
+		frame = append(frame, b0, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(payload)))
+	}
+
+	if c.isServer {
+		frame = append(frame, payload...)
+	} else {
+		var maskKey [4]byte
+		if _, err := rand.Read(maskKey[:]); err != nil {
+			return err
+		}
+
+		frame = append(frame, maskKey[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(maskKey, frame[start:])
+	}
+
+	_, err := c.conn.Write(frame)
+
+	return err
+}
+
+type fragmentWriter struct {
+	c      *Conn
+	op     int
+	closed bool
+}
+
+func (fw *fragmentWriter) Write(p []byte) (int, error) {
+	if fw.closed {
+		return 0, errors.New("doze: websocket writer already closed")
+	}
+
+	if err := fw.c.writeFrameLocked(false, fw.op, p); err != nil {
+		return 0, err
+	}
+
+	fw.op = continuationFrame
+
+	return len(p), nil
+}
+
+func (fw *fragmentWriter) Close() error {
+	if fw.closed {
+		return nil
+	}
+
+	fw.closed = true
+	defer fw.c.writeMu.Unlock()
+
+	return fw.c.writeFrameLocked(true, fw.op, nil)
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i%4]
+	}
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	io.WriteString(h, key+websocketGUID)
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
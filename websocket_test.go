@@ -0,0 +1,191 @@
+package doze
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dialWebSocket performs a client handshake against the test server and returns
+// a client side Conn
+func dialWebSocket(t *testing.T, server *httptest.Server, path string, header http.Header) (*Conn, *http.Response) {
+	netConn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	req.Header = header
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+
+	if err := req.Write(netConn); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(netConn)
+
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		netConn.Close()
+		return nil, resp
+	}
+
+	return newConn(netConn, br, false), resp
+}
+
+func newWebSocketServer(name string) *httptest.Server {
+	router := Router(name)
+	router.Add(NewRoute().For("/rooms/{room}").WebSocket(func(c *Context, conn *Conn) {
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			reply := c.Route.Params()["room"].(string) + ": " + string(message)
+			if err := conn.WriteMessage(messageType, []byte(reply)); err != nil {
+				return
+			}
+		}
+	}))
+
+	h := NewHandler(router)
+	h.Use(func(c *Context, next NextFunc) {
+		if c.Request.Header.Get("X-Auth") != "letmein" {
+			c.ResponseWriter.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		c.ResponseWriter.Header().Set("X-Authed", "yes")
+		if room := c.Request.URL.Query().Get("room"); room != "" {
+			c.ResponseWriter.Header().Set("X-Room", room)
+		}
+		next(c)
+	})
+
+	return httptest.NewServer(h)
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	server := newWebSocketServer("TestWebSocketEcho")
+	defer server.Close()
+
+	conn, resp := dialWebSocket(t, server, "/rooms/lobby", http.Header{"X-Auth": {"letmein"}})
+
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode, "they should be equal")
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"), "they should be equal")
+	assert.Equal(t, "yes", resp.Header.Get("X-Authed"), "middleware headers should be sent")
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	assert.Nil(t, conn.WriteMessage(TextMessage, []byte("hello")), "error should be nil")
+
+	messageType, message, err := conn.ReadMessage()
+
+	assert.Nil(t, err, "error should be nil")
+	assert.Equal(t, TextMessage, messageType, "they should be equal")
+	assert.Equal(t, "lobby: hello", string(message), "they should be equal")
+
+	// a fragmented message with a ping in between
+	w, _ := conn.Writer(BinaryMessage)
+	w.Write([]byte("frag"))
+	w.Write([]byte("mented"))
+	w.Close()
+
+	messageType, message, err = conn.ReadMessage()
+
+	assert.Nil(t, err, "error should be nil")
+	assert.Equal(t, BinaryMessage, messageType, "they should be equal")
+	assert.Equal(t, "lobby: fragmented", string(message), "they should be equal")
+
+	assert.Nil(t, conn.Ping([]byte("are you there")), "error should be nil")
+
+	_, op, payload, err := conn.readFrame()
+
+	assert.Nil(t, err, "error should be nil")
+	assert.Equal(t, PongMessage, op, "they should be equal")
+	assert.Equal(t, "are you there", string(payload), "they should be equal")
+
+	assert.Nil(t, conn.CloseWithCode(CloseGoingAway, "bye"), "error should be nil")
+	assert.True(t, conn.closeRecv, "server should answer the close frame")
+}
+
+func TestWebSocketProtocolError(t *testing.T) {
+	server := newWebSocketServer("TestWebSocketProtocolError")
+	defer server.Close()
+
+	conn, _ := dialWebSocket(t, server, "/rooms/lobby", http.Header{"X-Auth": {"letmein"}})
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	// a continuation frame without a message first
+	conn.writeFrame(true, continuationFrame, []byte("oops"))
+
+	_, _, err := conn.ReadMessage()
+
+	assert.Equal(t, &CloseError{Code: CloseProtocolError, Text: "continuation without a message"}, err, "they should be equal")
+}
+
+func TestWebSocketRejected(t *testing.T) {
+	server := newWebSocketServer("TestWebSocketRejected")
+	defer server.Close()
+
+	conn, resp := dialWebSocket(t, server, "/rooms/lobby", http.Header{})
+
+	assert.Nil(t, conn, "conn should be nil")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "middleware should run before the upgrade")
+
+	conn, resp = dialWebSocket(t, server, "/rooms/lobby", http.Header{"X-Auth": {"letmein"}, "Origin": {"http://evil.example.com"}})
+
+	assert.Nil(t, conn, "conn should be nil")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "cross origin requests should be rejected")
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/rooms/lobby", nil)
+	req.Header.Set("X-Auth", "letmein")
+
+	resp, _ = http.DefaultClient.Do(req)
+
+	assert.Equal(t, http.StatusUpgradeRequired, resp.StatusCode, "they should be equal")
+	assert.Equal(t, "websocket", resp.Header.Get("Upgrade"), "they should be equal")
+}
+
+func TestWebSocketNotHijackable(t *testing.T) {
+	router := Router("TestWebSocketNotHijackable")
+	router.Add(NewRoute().For("/ws").WebSocket(func(c *Context, conn *Conn) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+
+	recorder := httptest.NewRecorder()
+	NewHandler(router).ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code, "they should be equal")
+	assert.Equal(t, "websocket upgrade not supported", recorder.Body.String(), "they should be equal")
+}
+
+func TestWebSocketHeaderInjection(t *testing.T) {
+	server := newWebSocketServer("TestWebSocketHeaderInjection")
+	defer server.Close()
+
+	conn, resp := dialWebSocket(t, server, "/rooms/lobby?room=lobby%0d%0aSet-Cookie:%20session=stolen", http.Header{"X-Auth": {"letmein"}})
+	defer conn.Close()
+
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode, "they should be equal")
+	assert.Equal(t, "", resp.Header.Get("Set-Cookie"), "a header value should not split the handshake")
+	assert.Equal(t, "lobby  Set-Cookie: session=stolen", resp.Header.Get("X-Room"), "newlines should be replaced")
+}